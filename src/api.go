@@ -2,25 +2,120 @@ package main
 
 import (
   "bytes"
+  "context"
   "encoding/json"
+  "errors"
   "fmt"
   "io"
   "net/http"
+  "net/url"
+  "reflect"
+  "sort"
+  "strconv"
+  "strings"
   "time"
 )
 
 type Document struct {
-  ID            string `json:"id"`
-  Author        string `json:"author"`
-  Category      string `json:"category"`
-  HTMLContent   string `json:"html_content"`
-  Location      string `json:"location"`
-  PublishedDate any    `json:"published_date"`
-  SourceURL     string `json:"source_url"`
-  Summary       string `json:"summary"`
-  Title         string `json:"title"`
-  URL           string `json:"url"`
-  WordCount     int    `json:"word_count"`
+  ID              string    `json:"id"`
+  Author          string    `json:"author"`
+  Category        string    `json:"category"`
+  Content         string    `json:"content"`
+  CreatedAt       Timestamp `json:"created_at"`
+  FirstOpenedAt   Timestamp `json:"first_opened_at"`
+  HTMLContent     string    `json:"html_content"`
+  ImageURL        string    `json:"image_url"`
+  LastMovedAt     Timestamp `json:"last_moved_at"`
+  LastOpenedAt    Timestamp `json:"last_opened_at"`
+  Location        string    `json:"location"`
+  Notes           string    `json:"notes"`
+  ParentID        string    `json:"parent_id"`
+  PublishedDate   Timestamp `json:"published_date"`
+  ReadingProgress float64   `json:"reading_progress"`
+  ReadingTime     string    `json:"reading_time"`
+  SavedAt         Timestamp `json:"saved_at"`
+  SiteName        string    `json:"site_name"`
+  SourceURL       string    `json:"source_url"`
+  Summary         string    `json:"summary"`
+  Tags            Tags      `json:"tags"`
+  Title           string    `json:"title"`
+  UpdatedAt       Timestamp `json:"updated_at"`
+  URL             string    `json:"url"`
+  WordCount       int       `json:"word_count"`
+
+  // Fields the API sends that have no counterpart above, kept so they are
+  // written back out to the cache and exports unchanged.
+  Extra map[string]json.RawMessage `json:"-"`
+}
+
+var documentFields = jsonFieldNames(reflect.TypeFor[Document]())
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+  names := make(map[string]bool)
+
+  for i := range t.NumField() {
+    if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+      names[name] = true
+    }
+  }
+
+  return names
+}
+
+func (d *Document) UnmarshalJSON(data []byte) error {
+  type document Document
+
+  var decoded document
+
+  if err := json.Unmarshal(data, &decoded); err != nil {
+    return err
+  }
+
+  var fields map[string]json.RawMessage
+
+  if err := json.Unmarshal(data, &fields); err != nil {
+    return err
+  }
+
+  for name := range fields {
+    if documentFields[name] {
+      delete(fields, name)
+    }
+  }
+
+  decoded.Extra = nil
+
+  if len(fields) > 0 {
+    decoded.Extra = fields
+  }
+
+  *d = Document(decoded)
+
+  return nil
+}
+
+func (d Document) MarshalJSON() ([]byte, error) {
+  type document Document
+
+  data, err := json.Marshal(document(d))
+
+  if err != nil || len(d.Extra) == 0 {
+    return data, err
+  }
+
+  var fields map[string]json.RawMessage
+
+  if err := json.Unmarshal(data, &fields); err != nil {
+    return nil, err
+  }
+
+  for name, value := range d.Extra {
+    if _, known := fields[name]; !known {
+      fields[name] = value
+    }
+  }
+
+  return json.Marshal(fields)
 }
 
 type DocumentsResponse struct {
@@ -29,15 +124,66 @@ type DocumentsResponse struct {
   Results        []Document `json:"results"`
 }
 
+type DocumentListOptions struct {
+  Category     string
+  ID           string
+  Limit        int
+  Location     string
+  UpdatedAfter time.Time
+  WithContent  bool
+}
+
+type SaveDocumentRequest struct {
+  URL        string   `json:"url"`
+  Author     string   `json:"author,omitempty"`
+  Location   string   `json:"location,omitempty"`
+  SavedUsing string   `json:"saved_using,omitempty"`
+  Tags       []string `json:"tags,omitempty"`
+  Title      string   `json:"title,omitempty"`
+}
+
+type SaveDocumentResponse struct {
+  ID  string `json:"id"`
+  URL string `json:"url"`
+}
+
+type DocumentUpdate struct {
+  Location        string    `json:"location,omitempty"`
+  ReadingProgress *float64  `json:"reading_progress,omitempty"`
+  Tags            *[]string `json:"tags,omitempty"`
+}
+
+const maxPageSize = 100
+
+var errDocumentExists = errors.New("document already exists")
+
+var errDocumentNotFound = errors.New("document not found")
+
+type HighlightRequest struct {
+  Text          string `json:"text"`
+  Author        string `json:"author,omitempty"`
+  Category      string `json:"category,omitempty"`
+  HighlightedAt string `json:"highlighted_at,omitempty"`
+  Note          string `json:"note,omitempty"`
+  SourceType    string `json:"source_type,omitempty"`
+  SourceURL     string `json:"source_url,omitempty"`
+  Title         string `json:"title,omitempty"`
+}
+
+var errInvalidToken = errors.New("invalid token")
+
 type ReaderAPI struct {
   token   string
+  v2URL   string
   baseURL string
   client  *http.Client
+  onRetry func(RetryNotice)
 }
 
 func NewReaderAPI(token string) *ReaderAPI {
   return &ReaderAPI{
     token:   token,
+    v2URL:   "https://readwise.io/api/v2",
     baseURL: "https://readwise.io/api/v3",
     client: &http.Client{
       Timeout: 30 * time.Second,
@@ -45,20 +191,62 @@ func NewReaderAPI(token string) *ReaderAPI {
   }
 }
 
-func (r *ReaderAPI) makeRequest(method, endpoint string, body any) (*http.Response, error) {
-  var bodyReader io.Reader
+func (r *ReaderAPI) makeRequest(ctx context.Context, method, endpoint string, body any) (*http.Response, error) {
+  return r.send(ctx, method, r.baseURL+endpoint, body)
+}
+
+func (r *ReaderAPI) send(ctx context.Context, method, requestURL string, body any) (*http.Response, error) {
+  var jsonBody []byte
 
   if body != nil {
-    jsonBody, err := json.Marshal(body)
+    var err error
+
+    jsonBody, err = json.Marshal(body)
 
     if err != nil {
       return nil, fmt.Errorf("failed to marshal request body: %w", err)
     }
+  }
+
+  for attempt := 1; ; attempt++ {
+    resp, err := r.doRequest(ctx, method, requestURL, jsonBody)
 
-    bodyReader = bytes.NewBuffer(jsonBody)
+    if ctx.Err() != nil {
+      return resp, err
+    }
+
+    wait, reason, retry := retryDelay(method, resp, err, attempt)
+
+    if !retry || attempt == maxAttempts {
+      return resp, err
+    }
+
+    if resp != nil {
+      if err := resp.Body.Close(); err != nil {
+        fmt.Printf("Warning: failed to close response body: %v\n", err)
+      }
+    }
+
+    if r.onRetry != nil {
+      r.onRetry(RetryNotice{Attempt: attempt, Reason: reason, Wait: wait})
+    }
+
+    select {
+    case <-ctx.Done():
+      return nil, ctx.Err()
+    case <-time.After(wait):
+    }
   }
+}
+
+func (r *ReaderAPI) doRequest(ctx context.Context, method, requestURL string, jsonBody []byte) (*http.Response, error) {
+  var bodyReader io.Reader
 
-  req, err := http.NewRequest(method, r.baseURL+endpoint, bodyReader)
+  if jsonBody != nil {
+    bodyReader = bytes.NewReader(jsonBody)
+  }
+
+  req, err := http.NewRequestWithContext(ctx, method, requestURL, bodyReader)
 
   if err != nil {
     return nil, fmt.Errorf("failed to create request: %w", err)
@@ -76,47 +264,55 @@ func (r *ReaderAPI) makeRequest(method, endpoint string, body any) (*http.Respon
   return resp, nil
 }
 
-func (r *ReaderAPI) GetDocuments(location string, limit int) ([]Document, error) {
+func (r *ReaderAPI) GetDocuments(ctx context.Context, options DocumentListOptions) ([]Document, error) {
   var allDocuments []Document
 
   var pageCursor string
 
   for {
-    endpoint := "/list/?withHtmlContent=true"
+    query := url.Values{}
 
-    if location != "" {
-      endpoint += "&location=" + location
+    if options.WithContent {
+      query.Set("withHtmlContent", "true")
     }
 
-    if pageCursor != "" {
-      endpoint += "&pageCursor=" + pageCursor
+    if options.ID != "" {
+      query.Set("id", options.ID)
     }
 
-    resp, err := r.makeRequest("GET", endpoint, nil)
+    if options.Location != "" {
+      query.Set("location", options.Location)
+    }
 
-    if err != nil {
-      return nil, err
+    if options.Category != "" {
+      query.Set("category", options.Category)
     }
 
-    defer func() {
-      if err := resp.Body.Close(); err != nil {
-        fmt.Printf("Warning: failed to close response body: %v\n", err)
-      }
-    }()
+    if !options.UpdatedAfter.IsZero() {
+      query.Set("updatedAfter", options.UpdatedAfter.UTC().Format(time.RFC3339))
+    }
 
-    if resp.StatusCode != http.StatusOK {
-      return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+    pageSize := maxPageSize
+
+    if options.Limit > 0 {
+      pageSize = min(options.Limit-len(allDocuments), maxPageSize)
     }
 
-    var documentsResp DocumentsResponse
+    query.Set("limit", strconv.Itoa(pageSize))
 
-    if err := json.NewDecoder(resp.Body).Decode(&documentsResp); err != nil {
-      return nil, fmt.Errorf("failed to decode response: %w", err)
+    if pageCursor != "" {
+      query.Set("pageCursor", pageCursor)
+    }
+
+    documentsResp, err := r.getDocumentsPage(ctx, query)
+
+    if err != nil {
+      return nil, err
     }
 
     allDocuments = append(allDocuments, documentsResp.Results...)
 
-    if documentsResp.NextPageCursor == "" {
+    if documentsResp.NextPageCursor == "" || (options.Limit > 0 && len(allDocuments) >= options.Limit) {
       break
     }
 
@@ -126,10 +322,45 @@ func (r *ReaderAPI) GetDocuments(location string, limit int) ([]Document, error)
   return allDocuments, nil
 }
 
-func (r *ReaderAPI) GetDocumentContent(documentID string) (string, error) {
+func (r *ReaderAPI) getDocumentsPage(ctx context.Context, query url.Values) (*DocumentsResponse, error) {
+  resp, err := r.makeRequest(ctx, "GET", "/list/?"+query.Encode(), nil)
+
+  if err != nil {
+    return nil, err
+  }
+
+  defer func() {
+    if err := resp.Body.Close(); err != nil {
+      fmt.Printf("Warning: failed to close response body: %v\n", err)
+    }
+  }()
+
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+  }
+
+  var documentsResp DocumentsResponse
+
+  if err := json.NewDecoder(resp.Body).Decode(&documentsResp); err != nil {
+    return nil, fmt.Errorf("failed to decode response: %w", err)
+  }
+
+  return &documentsResp, nil
+}
+
+// Highlights can't be listed for a single document, so this returns every
+// highlight in the library changed since `since`.
+func (r *ReaderAPI) GetHighlights(ctx context.Context, since time.Time) ([]Document, error) {
+  return r.GetDocuments(ctx, DocumentListOptions{
+    Category:     "highlight",
+    UpdatedAfter: since,
+  })
+}
+
+func (r *ReaderAPI) GetDocumentContent(ctx context.Context, documentID string) (string, error) {
   endpoint := "/list/?id=" + documentID + "&withHtmlContent=true"
 
-  resp, err := r.makeRequest("GET", endpoint, nil)
+  resp, err := r.makeRequest(ctx, "GET", endpoint, nil)
 
   if err != nil {
     return "", err
@@ -158,8 +389,105 @@ func (r *ReaderAPI) GetDocumentContent(documentID string) (string, error) {
   return documentsResp.Results[0].Summary, nil
 }
 
-func (r *ReaderAPI) ValidateToken() error {
-  resp, err := http.Get("https://readwise.io/api/v2/auth/")
+func (r *ReaderAPI) SaveDocument(ctx context.Context, request SaveDocumentRequest) (*SaveDocumentResponse, error) {
+  resp, err := r.makeRequest(ctx, "POST", "/save/", request)
+
+  if err != nil {
+    return nil, err
+  }
+
+  defer func() {
+    if err := resp.Body.Close(); err != nil {
+      fmt.Printf("Warning: failed to close response body: %v\n", err)
+    }
+  }()
+
+  if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+    return nil, responseError(resp)
+  }
+
+  var saveResp SaveDocumentResponse
+
+  if err := json.NewDecoder(resp.Body).Decode(&saveResp); err != nil {
+    return nil, fmt.Errorf("failed to decode response: %w", err)
+  }
+
+  // Reader answers with 200 instead of 201 when the URL is already saved.
+  if resp.StatusCode == http.StatusOK {
+    return &saveResp, errDocumentExists
+  }
+
+  return &saveResp, nil
+}
+
+func (r *ReaderAPI) UpdateDocument(ctx context.Context, documentID string, update DocumentUpdate) error {
+  resp, err := r.makeRequest(ctx, "PATCH", "/update/"+documentID+"/", update)
+
+  if err != nil {
+    return err
+  }
+
+  defer func() {
+    if err := resp.Body.Close(); err != nil {
+      fmt.Printf("Warning: failed to close response body: %v\n", err)
+    }
+  }()
+
+  if resp.StatusCode == http.StatusNotFound {
+    return errDocumentNotFound
+  }
+
+  if resp.StatusCode != http.StatusOK {
+    return responseError(resp)
+  }
+
+  return nil
+}
+
+func (r *ReaderAPI) DeleteDocument(ctx context.Context, documentID string) error {
+  resp, err := r.makeRequest(ctx, "DELETE", "/delete/"+documentID+"/", nil)
+
+  if err != nil {
+    return err
+  }
+
+  defer func() {
+    if err := resp.Body.Close(); err != nil {
+      fmt.Printf("Warning: failed to close response body: %v\n", err)
+    }
+  }()
+
+  if resp.StatusCode != http.StatusNoContent {
+    return responseError(resp)
+  }
+
+  return nil
+}
+
+func (r *ReaderAPI) CreateHighlight(ctx context.Context, highlight HighlightRequest) error {
+  body := map[string][]HighlightRequest{"highlights": {highlight}}
+
+  resp, err := r.send(ctx, "POST", r.v2URL+"/highlights/", body)
+
+  if err != nil {
+    return err
+  }
+
+  defer func() {
+    if err := resp.Body.Close(); err != nil {
+      fmt.Printf("Warning: failed to close response body: %v\n", err)
+    }
+  }()
+
+  if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+    return responseError(resp)
+  }
+
+  return nil
+}
+
+func (r *ReaderAPI) ValidateToken(ctx context.Context) error {
+  resp, err := r.send(ctx, "GET", r.v2URL+"/auth/", nil)
 
   if err != nil {
     return fmt.Errorf("failed to validate token: %w", err)
@@ -171,9 +499,52 @@ func (r *ReaderAPI) ValidateToken() error {
     }
   }()
 
+  if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+    return errInvalidToken
+  }
+
   if resp.StatusCode != http.StatusNoContent {
-    return fmt.Errorf("invalid token")
+    return responseError(resp)
   }
 
   return nil
 }
+
+func responseError(resp *http.Response) error {
+  body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+  message := strings.TrimSpace(string(body))
+
+  var fields map[string]any
+
+  if json.Unmarshal(body, &fields) == nil && len(fields) > 0 {
+    keys := make([]string, 0, len(fields))
+
+    for key := range fields {
+      keys = append(keys, key)
+    }
+
+    sort.Strings(keys)
+
+    var parts []string
+
+    for _, key := range keys {
+      switch value := fields[key].(type) {
+      case []any:
+        for _, item := range value {
+          parts = append(parts, fmt.Sprintf("%s: %v", key, item))
+        }
+      default:
+        parts = append(parts, fmt.Sprintf("%s: %v", key, value))
+      }
+    }
+
+    message = strings.Join(parts, ", ")
+  }
+
+  if message == "" {
+    return fmt.Errorf("API request failed with status %d", resp.StatusCode)
+  }
+
+  return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, message)
+}
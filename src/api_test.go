@@ -0,0 +1,260 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "strconv"
+  "testing"
+)
+
+func newTestAPI(t *testing.T, handler http.HandlerFunc) *ReaderAPI {
+  t.Helper()
+
+  server := httptest.NewServer(handler)
+
+  t.Cleanup(server.Close)
+
+  api := NewReaderAPI("token")
+
+  api.baseURL = server.URL
+  api.v2URL = server.URL
+
+  return api
+}
+
+// The fake list endpoint serves `total` documents in pages of the requested
+// size.
+func listHandler(t *testing.T, total int, pages *int) http.HandlerFunc {
+  return func(w http.ResponseWriter, r *http.Request) {
+    *pages++
+
+    limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+
+    if err != nil || limit <= 0 || limit > maxPageSize {
+      t.Errorf("invalid page size %q", r.URL.Query().Get("limit"))
+      limit = maxPageSize
+    }
+
+    start, _ := strconv.Atoi(r.URL.Query().Get("pageCursor"))
+
+    end := min(start+limit, total)
+
+    var response DocumentsResponse
+
+    for i := start; i < end; i++ {
+      response.Results = append(response.Results, Document{ID: fmt.Sprintf("doc-%d", i)})
+    }
+
+    if end < total {
+      response.NextPageCursor = strconv.Itoa(end)
+    }
+
+    if err := json.NewEncoder(w).Encode(response); err != nil {
+      t.Error(err)
+    }
+  }
+}
+
+func TestGetDocumentsLimit(t *testing.T) {
+  tests := []struct {
+    name  string
+    total int
+    limit int
+    want  int
+    pages int
+  }{
+    {name: "no limit", total: 250, limit: 0, want: 250, pages: 3},
+    {name: "limit within one page", total: 250, limit: 30, want: 30, pages: 1},
+    {name: "limit across pages", total: 250, limit: 150, want: 150, pages: 2},
+    {name: "limit above total", total: 20, limit: 50, want: 20, pages: 1},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      pages := 0
+
+      api := newTestAPI(t, listHandler(t, test.total, &pages))
+
+      docs, err := api.GetDocuments(context.Background(), DocumentListOptions{Limit: test.limit})
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if len(docs) != test.want {
+        t.Errorf("got %d documents, want %d", len(docs), test.want)
+      }
+
+      if pages != test.pages {
+        t.Errorf("made %d requests, want %d", pages, test.pages)
+      }
+    })
+  }
+}
+
+func TestDocumentJSONRoundTrip(t *testing.T) {
+  tests := []struct {
+    name  string
+    data  string
+    extra []string
+  }{
+    {
+      name: "known fields only",
+      data: `{"id": "a", "title": "Title", "tags": {}}`,
+    },
+    {
+      name:  "unknown fields",
+      data:  `{"id": "a", "title": "Title", "reading_state": {"position":3}, "is_favorite": true}`,
+      extra: []string{"is_favorite", "reading_state"},
+    },
+    {
+      name:  "null unknown field",
+      data:  `{"id": "a", "image_caption": null}`,
+      extra: []string{"image_caption"},
+    },
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      var doc Document
+
+      if err := json.Unmarshal([]byte(test.data), &doc); err != nil {
+        t.Fatal(err)
+      }
+
+      if len(doc.Extra) != len(test.extra) {
+        t.Fatalf("expected extra fields %v, got %v", test.extra, doc.Extra)
+      }
+
+      data, err := json.Marshal(doc)
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      var original, encoded map[string]json.RawMessage
+
+      if err := json.Unmarshal([]byte(test.data), &original); err != nil {
+        t.Fatal(err)
+      }
+
+      if err := json.Unmarshal(data, &encoded); err != nil {
+        t.Fatal(err)
+      }
+
+      for _, name := range test.extra {
+        if string(encoded[name]) != string(original[name]) {
+          t.Errorf("expected %s to be written back as %s, got %s", name, original[name], encoded[name])
+        }
+      }
+
+      var decoded Document
+
+      if err := json.Unmarshal(data, &decoded); err != nil {
+        t.Fatal(err)
+      }
+
+      if decoded.ID != doc.ID || decoded.Title != doc.Title || len(decoded.Extra) != len(doc.Extra) {
+        t.Errorf("expected %+v after a round trip, got %+v", doc, decoded)
+      }
+    })
+  }
+}
+
+func TestSaveDocument(t *testing.T) {
+  tests := []struct {
+    name   string
+    status int
+    body   string
+    err    error
+    failed bool
+  }{
+    {name: "new document", status: http.StatusCreated, body: `{"id": "new", "url": "https://read.readwise.io/new"}`},
+    {name: "already saved", status: http.StatusOK, body: `{"id": "old", "url": "https://read.readwise.io/old"}`, err: errDocumentExists},
+    {name: "rejected", status: http.StatusBadRequest, body: `{"url": ["Enter a valid URL."]}`, failed: true},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+        var request SaveDocumentRequest
+
+        if r.Method != http.MethodPost || r.URL.Path != "/save/" {
+          t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+        }
+
+        if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.URL != "https://example.com" {
+          t.Errorf("unexpected request body %+v: %v", request, err)
+        }
+
+        w.WriteHeader(test.status)
+
+        if _, err := w.Write([]byte(test.body)); err != nil {
+          t.Error(err)
+        }
+      })
+
+      resp, err := api.SaveDocument(context.Background(), SaveDocumentRequest{URL: "https://example.com"})
+
+      if test.failed {
+        if err == nil || errors.Is(err, errDocumentExists) {
+          t.Fatalf("expected a request error, got %v", err)
+        }
+
+        return
+      }
+
+      if !errors.Is(err, test.err) {
+        t.Fatalf("expected error %v, got %v", test.err, err)
+      }
+
+      if resp == nil || resp.ID == "" || resp.URL == "" {
+        t.Errorf("expected the saved document, got %+v", resp)
+      }
+    })
+  }
+}
+
+func TestValidateToken(t *testing.T) {
+  tests := []struct {
+    name   string
+    status int
+    err    error
+    failed bool
+  }{
+    {name: "valid", status: http.StatusNoContent},
+    {name: "unauthorized", status: http.StatusUnauthorized, err: errInvalidToken, failed: true},
+    {name: "forbidden", status: http.StatusForbidden, err: errInvalidToken, failed: true},
+    {name: "unexpected status", status: http.StatusBadRequest, failed: true},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/auth/" || r.Header.Get("Authorization") != "Token token" {
+          t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+        }
+
+        w.WriteHeader(test.status)
+      })
+
+      err := api.ValidateToken(context.Background())
+
+      if (err != nil) != test.failed {
+        t.Fatalf("expected failure %v, got %v", test.failed, err)
+      }
+
+      if test.err != nil && !errors.Is(err, test.err) {
+        t.Errorf("expected %v, got %v", test.err, err)
+      }
+
+      if test.err == nil && errors.Is(err, errInvalidToken) {
+        t.Errorf("expected %d not to be reported as an invalid token", test.status)
+      }
+    })
+  }
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+  "context"
+  "errors"
   "fmt"
+  "github.com/charmbracelet/bubbles/key"
   tea "github.com/charmbracelet/bubbletea"
   "github.com/charmbracelet/glamour"
+  "github.com/charmbracelet/lipgloss"
+  "github.com/charmbracelet/x/ansi"
+  "github.com/muesli/termenv"
   "log"
+  "math"
   "os"
+  "slices"
   "strings"
+  "time"
 )
 
 type state int
@@ -14,60 +23,233 @@ type state int
 const (
   documentListView state = iota
   documentReadView
+  highlightsView
+  imagesView
+  linksView
 )
 
-type allDocumentsLoadedMsg []Document
-type documentContentMsg string
+type cachedDocumentsLoadedMsg struct {
+  documents []Document
+  err       error
+  lastSync  time.Time
+}
+
+type documentsSyncedMsg struct {
+  category  string
+  documents []Document
+  full      bool
+  syncedAt  time.Time
+}
+
+type documentContentMsg struct {
+  id      string
+  content string
+  images  []ContentImage
+  links   []ContentLink
+}
+
+type externalCommandFinishedMsg struct {
+  err error
+}
 type errorMsg error
 
+type documentMovedMsg struct {
+  id       string
+  location string
+}
+
+type documentMoveFailedMsg struct {
+  id       string
+  location string
+  previous string
+  err      error
+}
+
+type readingProgressSaveFailedMsg struct {
+  err error
+}
+
+type documentDeletedMsg struct {
+  doc Document
+}
+
+type documentDeleteFailedMsg struct {
+  doc Document
+  err error
+}
+
 type App struct {
   allDocuments     []Document
   api              *ReaderAPI
+  cache            *DocumentCache
+  config           *Config
+  cancel           context.CancelFunc
   categories       []Category
+  columns          []Column
   content          string
   contentLines     []string
+  ctx              context.Context
+  current          Document
+  deleteID         string
   currentLocation  string
+  currentType      string
   documents        []Document
+  editingTags      bool
+  editingTagsID    string
   err              error
   height           int
+  highlightCursor  int
+  highlightDocs    []Document
+  highlights       map[string][]Document
+  highlightLines   map[string]int
+  keys             KeyMap
+  imageCursor      int
+  images           []ContentImage
+  lastSync         time.Time
+  linkCursor       int
+  links            []ContentLink
   loading          bool
+  notingHighlight  bool
+  noteInput        string
+  offline          bool
   renderer         *glamour.TermRenderer
+  renderStyle      string
+  renderWidth      int
+  retries          chan RetryNotice
   scrollOffset     int
+  searchIndex      *SearchIndex
+  searchQuery      string
+  searching        bool
   selected         int
   selectedCategory int
+  selectedTag      string
+  selecting        bool
+  selectionAnchor  int
+  selectionCursor  int
+  showTags         bool
+  sortDescending   bool
+  sortMode         string
   state            state
+  status           string
+  stopSync         context.CancelFunc
+  syncing          bool
+  tagCounts        []TagCount
+  tagCursor        int
+  tagInput         string
   width            int
 }
 
 func (m App) Init() tea.Cmd {
-  return loadAllDocuments(m.api)
+  return tea.Batch(loadCachedDocuments(m.cache), listenForRetries(m.retries))
 }
 
-func NewModel() App {
-  token, err := getToken()
+func NewModel(offline bool) App {
+  var api *ReaderAPI
+
+  retries := make(chan RetryNotice, 16)
+
+  ctx, cancel := context.WithCancel(context.Background())
+
+  if !offline {
+    token, err := getToken()
+
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+      os.Exit(1)
+    }
+
+    api = NewReaderAPI(token)
+    api.onRetry = notifyRetries(retries)
+  }
+
+  config, err := loadConfig()
 
   if err != nil {
-    fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
-    os.Exit(1)
+    log.Fatal(err)
   }
 
-  api := NewReaderAPI(token)
+  cache, err := openDocumentCache()
 
-  renderer, err := glamour.NewTermRenderer(
-    glamour.WithAutoStyle(),
-    glamour.WithWordWrap(80),
-  )
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  theme, err := loadTheme(config.Theme)
+
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  if noColor() {
+    lipgloss.SetColorProfile(termenv.Ascii)
+  }
+
+  styles = newStyles(theme)
+
+  renderStyle := theme.Glamour
+
+  if config.GlamourStyle != "" && !noColor() {
+    renderStyle = config.GlamourStyle
+  }
+
+  // The background is only queried once, since asking the terminal again
+  // while the program is running would interfere with its input.
+  if renderStyle == "auto" || renderStyle == "" {
+    renderStyle = "light"
+
+    if lipgloss.HasDarkBackground() {
+      renderStyle = "dark"
+    }
+  }
+
+  keys, err := newKeyMap(config.Keys)
+
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  columns, err := listColumns(config.Columns)
+
+  if err != nil {
+    log.Fatal(err)
+  }
+
+  if err := validateSort(config.Sort, config.SortOrder); err != nil {
+    log.Fatal(err)
+  }
+
+  sortMode := config.Sort
+
+  if sortMode == "" {
+    sortMode = sortModes[0]
+  }
+
+  renderWidth := config.wrapWidth(80)
+
+  renderer, err := newRenderer(renderStyle, renderWidth)
 
   if err != nil {
     log.Fatal(err)
   }
 
   return App{
-    state:    documentListView,
-    api:      api,
-    loading:  true,
-    selected: 0,
-    renderer: renderer,
+    state:          documentListView,
+    api:            api,
+    cache:          cache,
+    config:         config,
+    columns:        columns,
+    sortMode:       sortMode,
+    sortDescending: config.SortOrder != "asc",
+    keys:           keys,
+    loading:        true,
+    offline:        offline,
+    selected:       0,
+    renderer:       renderer,
+    renderStyle:    renderStyle,
+    renderWidth:    renderWidth,
+    retries:        retries,
+    ctx:            ctx,
+    cancel:         cancel,
   }
 }
 
@@ -77,6 +259,12 @@ func (m App) View() string {
     return m.renderDocumentList()
   case documentReadView:
     return m.renderDocument()
+  case highlightsView:
+    return m.renderHighlights()
+  case imagesView:
+    return m.renderImages()
+  case linksView:
+    return m.renderLinks()
   default:
     return "Unknown state"
   }
@@ -84,83 +272,476 @@ func (m App) View() string {
 
 func (m App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
   switch msg := msg.(type) {
-  case allDocumentsLoadedMsg:
-    allDocs := []Document(msg)
+  case cachedDocumentsLoadedMsg:
+    m.allDocuments = listedDocuments(msg.documents)
+    m.setHighlights(msg.documents)
+    m.lastSync = msg.lastSync
+    m.rebuildCategories()
+
+    if msg.err != nil {
+      m.status = fmt.Sprintf("Failed to read cache: %s", msg.err.Error())
+    }
 
-    filteredDocs := make([]Document, 0)
+    if m.offline {
+      m.loading = false
 
-    for _, doc := range allDocs {
-      if strings.TrimSpace(doc.Title) != "" {
-        filteredDocs = append(filteredDocs, doc)
+      if m.status == "" {
+        m.status = "Offline mode"
       }
+
+      return m, buildSearchIndex(m.allDocuments, m.searchIndex)
+    }
+
+    m.loading = len(m.allDocuments) == 0
+
+    cmd := m.sync(m.lastSync, "")
+
+    // Positions saved offline go out first, so the sync doesn't overwrite
+    // them with the older ones on the server.
+    return m, tea.Batch(tea.Sequence(sendQueuedProgress(m.ctx, m.api), cmd), buildSearchIndex(m.allDocuments, m.searchIndex))
+  case documentsSyncedMsg:
+    docs := msg.documents
+    highlightDocs := docs
+
+    switch {
+    case !msg.full:
+      docs = mergeDocuments(m.allDocuments, docs)
+      highlightDocs = mergeDocuments(m.highlightDocs, highlightDocs)
+    case msg.category != "":
+      docs = mergeDocuments(withoutReaderCategory(m.allDocuments, msg.category), docs)
+      highlightDocs = mergeDocuments(withoutReaderCategory(m.highlightDocs, msg.category), highlightDocs)
+    }
+
+    // Highlights created here only exist locally, so a full sync keeps them.
+    if msg.full {
+      highlightDocs = mergeDocuments(localHighlights(m.highlightDocs), highlightDocs)
+    }
+
+    m.allDocuments = listedDocuments(docs)
+    m.setHighlights(highlightDocs)
+
+    if msg.category == "" {
+      m.lastSync = msg.syncedAt
     }
 
-    m.allDocuments = filteredDocs
-    m.categories = buildCategories(filteredDocs)
     m.loading = false
+    m.syncing = false
+    m.status = ""
     m.err = nil
-    m.selectedCategory = 0
+    m.rebuildCategories()
 
-    if len(m.categories) > 0 {
-      m.currentLocation = m.categories[0].Location
-      m.documents = m.filterDocumentsByLocation(m.currentLocation)
-    }
+    return m, buildSearchIndex(m.allDocuments, m.searchIndex)
+  case searchIndexBuiltMsg:
+    m.searchIndex = msg
 
-    m.state = documentListView
+    if m.searchQuery != "" {
+      m.documents = m.visibleDocuments()
+      m.selected = max(0, min(m.selected, len(m.documents)-1))
+    }
   case documentContentMsg:
-    content := string(msg)
+    // A document left before its content loaded can finish converting after
+    // another one is opened, and mustn't replace that one's content.
+    if msg.id != m.current.ID || m.state == documentListView {
+      return m, nil
+    }
 
+    content := msg.content
+
+    m.images = msg.images
+    m.links = msg.links
+    m.linkCursor = -1
     m.content = content
-    m.scrollOffset = 0
+    m.renderContent()
+    m.scrollOffset = int(math.Round(m.current.ReadingProgress * float64(m.maxScroll())))
+  case documentMovedMsg:
+    m.status = fmt.Sprintf("Moved to %s", locationName(msg.location))
+  case documentMoveFailedMsg:
+    if doc, ok := m.findDocument(msg.id); ok && doc.Location == msg.location {
+      m.updateDocument(msg.id, func(doc *Document) { doc.Location = msg.previous })
+    }
+    m.status = fmt.Sprintf("Failed to move document: %s", msg.err.Error())
+  case retryMsg:
+    m.status = RetryNotice(msg).String()
+
+    return m, listenForRetries(m.retries)
+  case highlightCreatedMsg:
+    doc := msg.highlight
+
+    if m.highlights == nil {
+      m.highlights = make(map[string][]Document)
+    }
+
+    m.highlightDocs = append(m.highlightDocs, doc)
+    m.highlights[doc.ParentID] = append(m.highlights[doc.ParentID], doc)
+    m.status = "Highlight saved to Readwise"
+
+    if msg.cacheErr != nil {
+      m.status = fmt.Sprintf("Highlight saved to Readwise, but failed to update cache: %s", msg.cacheErr.Error())
+    }
 
-    if rendered, err := m.renderer.Render(content); err == nil {
-      m.contentLines = strings.Split(rendered, "\n")
+    if m.state != documentListView && m.current.ID == doc.ParentID {
+      m.renderContent()
+    }
+  case externalCommandFinishedMsg:
+    if msg.err != nil {
+      m.status = fmt.Sprintf("Failed to open: %s", msg.err.Error())
+    }
+  case readingProgressSaveFailedMsg:
+    if !errors.Is(msg.err, context.Canceled) {
+      m.status = fmt.Sprintf("Failed to save reading progress: %s", msg.err.Error())
+    }
+  case cacheWriteFailedMsg:
+    m.status = fmt.Sprintf("Failed to update cache: %s", msg.err.Error())
+  case configSaveFailedMsg:
+    m.status = fmt.Sprintf("Failed to save config: %s", msg.err.Error())
+  case linkSavedMsg:
+    if msg.existed {
+      m.status = fmt.Sprintf("Already in Reader: %s", msg.url)
     } else {
-      m.contentLines = strings.Split(content, "\n")
+      m.status = fmt.Sprintf("Saved to Reader: %s", msg.url)
+    }
+  case clipboardCopiedMsg:
+    m.status = fmt.Sprintf("Copied %s", msg.text)
+  case clipboardCopyFailedMsg:
+    m.status = fmt.Sprintf("Failed to copy: %s", msg.err.Error())
+  case linkSaveFailedMsg:
+    m.status = fmt.Sprintf("Failed to save link: %s", msg.err.Error())
+  case highlightCreateFailedMsg:
+    m.status = fmt.Sprintf("Failed to save highlight: %s", msg.err.Error())
+  case documentTagsUpdatedMsg:
+    m.status = "Updated tags"
+  case documentTagsUpdateFailedMsg:
+    if doc, ok := m.findDocument(msg.id); ok && doc.Tags.String() == msg.tags.String() {
+      m.updateDocument(msg.id, func(doc *Document) { doc.Tags = msg.previous })
     }
+    m.status = fmt.Sprintf("Failed to update tags: %s", msg.err.Error())
+  case documentDeletedMsg:
+    m.status = fmt.Sprintf("Deleted %s", msg.doc.Title)
+  case documentDeleteFailedMsg:
+    m.allDocuments = append(m.allDocuments, msg.doc)
+    m.rebuildCategories()
+    m.status = fmt.Sprintf("Failed to delete document: %s", msg.err.Error())
   case errorMsg:
-    m.err = error(msg)
+    if errors.Is(error(msg), context.Canceled) {
+      return m, nil
+    }
+
     m.loading = false
+    m.syncing = false
+
+    // Keep showing cached documents when a background sync fails.
+    if len(m.allDocuments) > 0 {
+      m.status = fmt.Sprintf("Sync failed: %s", error(msg).Error())
+    } else {
+      m.err = error(msg)
+    }
   case tea.WindowSizeMsg:
     m.width = msg.Width
     m.height = msg.Height
+
+    if width := m.config.wrapWidth(m.width); width != m.renderWidth {
+      renderer, err := newRenderer(m.renderStyle, width)
+
+      if err != nil {
+        m.status = fmt.Sprintf("Failed to create renderer: %s", err.Error())
+        return m, nil
+      }
+
+      m.renderer = renderer
+      m.renderWidth = width
+
+      if m.content != "" {
+        m.rerenderContent()
+      }
+    }
+
+    m.scrollOffset = min(m.scrollOffset, m.maxScroll())
   case tea.KeyMsg:
-    switch msg.String() {
-    case "ctrl+c", "q":
-      return m, tea.Quit
-    case "up":
-      if m.state == documentListView && len(m.documents) > 0 && m.selected > 0 {
-        m.selected--
-      } else if m.state == documentReadView && m.scrollOffset > 0 {
-        m.scrollOffset--
+    if m.searching {
+      switch msg.Type {
+      case tea.KeyCtrlC:
+        return m, m.quit()
+      case tea.KeyEnter:
+        m.searching = false
+        return m, nil
+      case tea.KeyEsc:
+        m.searching = false
+        m.setSearchQuery("")
+        return m, nil
+      case tea.KeyBackspace, tea.KeyRunes, tea.KeySpace:
+        m.setSearchQuery(editLine(m.searchQuery, msg))
+        return m, nil
+      default:
+        if !key.Matches(msg, m.keys.Up, m.keys.Down, m.keys.PageUp, m.keys.PageDown) {
+          return m, nil
+        }
       }
-    case "down":
-      if m.state == documentListView && len(m.documents) > 0 && m.selected < len(m.documents)-1 {
-        m.selected++
-      } else if m.state == documentReadView && len(m.contentLines) > 0 {
-        maxScroll := len(m.contentLines) - (m.height - 6)
-        maxScroll = max(maxScroll, 0)
-        if m.scrollOffset < maxScroll {
-          m.scrollOffset++
+    }
+
+    if m.editingTags {
+      switch msg.Type {
+      case tea.KeyCtrlC:
+        return m, m.quit()
+      case tea.KeyEnter:
+        m.editingTags = false
+
+        // The list can change while the tags are typed, so the document is
+        // the one editing started on rather than whichever is selected now.
+        doc, ok := m.findDocument(m.editingTagsID)
+
+        if !ok {
+          m.status = ""
+          return m, nil
+        }
+
+        previous := doc.Tags
+        doc.Tags = newTags(splitTags(m.tagInput))
+
+        if doc.Tags.String() == previous.String() {
+          m.status = ""
+          return m, nil
+        }
+
+        m.updateDocument(doc.ID, func(d *Document) { d.Tags = doc.Tags })
+        m.status = "Updating tags..."
+
+        return m, updateDocumentTags(m.ctx, m.api, m.cache, doc, previous)
+      case tea.KeyEsc:
+        m.editingTags = false
+        m.status = ""
+      default:
+        m.tagInput = editLine(m.tagInput, msg)
+      }
+
+      return m, nil
+    }
+
+    if m.showTags {
+      switch {
+      case key.Matches(msg, m.keys.Quit):
+        return m, m.quit()
+      case key.Matches(msg, m.keys.Up):
+        m.tagCursor = max(0, m.tagCursor-1)
+      case key.Matches(msg, m.keys.Down):
+        m.tagCursor = min(len(m.tagCounts), m.tagCursor+1)
+      case key.Matches(msg, m.keys.Select):
+        m.selectedTag = ""
+
+        if m.tagCursor > 0 {
+          m.selectedTag = m.tagCounts[m.tagCursor-1].Name
+        }
+
+        m.showTags = false
+        m.documents = m.visibleDocuments()
+        m.selected = 0
+      case key.Matches(msg, m.keys.Cancel, m.keys.Tags):
+        m.showTags = false
+      }
+
+      return m, nil
+    }
+
+    if m.notingHighlight {
+      switch msg.Type {
+      case tea.KeyCtrlC:
+        return m, m.quit()
+      case tea.KeyEnter:
+        m.notingHighlight = false
+
+        start, end := m.selectionRange()
+        text := selectionText(m.content, m.contentLines, start, end)
+
+        if strings.TrimSpace(text) == "" {
+          m.status = "Nothing selected"
+          return m, nil
+        }
+
+        m.status = "Saving highlight..."
+
+        return m, createHighlight(m.ctx, m.api, m.cache, m.current, text, strings.TrimSpace(m.noteInput))
+      case tea.KeyEsc:
+        m.notingHighlight = false
+        m.status = ""
+      default:
+        m.noteInput = editLine(m.noteInput, msg)
+      }
+
+      return m, nil
+    }
+
+    if m.selecting {
+      switch {
+      case msg.Type == tea.KeyCtrlC:
+        return m, m.quit()
+      case key.Matches(msg, m.keys.Up):
+        m.moveSelection(-1)
+      case key.Matches(msg, m.keys.Down):
+        m.moveSelection(1)
+      case key.Matches(msg, m.keys.PageUp):
+        m.moveSelection(-max(1, (m.height-6)/2))
+      case key.Matches(msg, m.keys.PageDown):
+        m.moveSelection(max(1, (m.height-6)/2))
+      case key.Matches(msg, m.keys.Visual, m.keys.Select):
+        m.selecting = false
+
+        if m.offline {
+          m.status = "Not available in offline mode"
+          return m, nil
+        }
+
+        m.notingHighlight = true
+        m.noteInput = ""
+      case key.Matches(msg, m.keys.Cancel):
+        m.selecting = false
+      }
+
+      return m, nil
+    }
+
+    if m.state == imagesView {
+      switch {
+      case key.Matches(msg, m.keys.Quit):
+        return m, m.quit()
+      case key.Matches(msg, m.keys.Up):
+        m.imageCursor = max(0, m.imageCursor-1)
+      case key.Matches(msg, m.keys.Down):
+        m.imageCursor = max(0, min(len(m.images)-1, m.imageCursor+1))
+      case key.Matches(msg, m.keys.Select):
+        if len(m.images) > 0 {
+          m.state = documentReadView
+
+          return m, m.openExternal(m.config.ImageViewer, m.images[m.imageCursor].URL)
+        }
+      case key.Matches(msg, m.keys.Back, m.keys.Images):
+        m.state = documentReadView
+      }
+
+      return m, nil
+    }
+
+    if m.state == linksView {
+      switch {
+      case key.Matches(msg, m.keys.Quit):
+        return m, m.quit()
+      case key.Matches(msg, m.keys.Up):
+        m.linkCursor = max(0, m.linkCursor-1)
+      case key.Matches(msg, m.keys.Down):
+        m.linkCursor = max(0, min(len(m.links)-1, m.linkCursor+1))
+      case key.Matches(msg, m.keys.Select):
+        if len(m.links) > 0 {
+          m.state = documentReadView
+          m.scrollToLink()
+
+          return m, m.openExternal(m.config.browserCommand(), m.links[m.linkCursor].URL)
+        }
+      case key.Matches(msg, m.keys.SaveLink):
+        if len(m.links) > 0 {
+          cmd := m.saveSelectedLink()
+
+          return m, cmd
         }
+      case key.Matches(msg, m.keys.Back, m.keys.Links):
+        m.state = documentReadView
+        m.scrollToLink()
+      }
+
+      return m, nil
+    }
+
+    if m.state == highlightsView {
+      highlights := m.highlights[m.current.ID]
+
+      switch {
+      case key.Matches(msg, m.keys.Quit):
+        return m, m.quit()
+      case key.Matches(msg, m.keys.Up):
+        m.highlightCursor = max(0, m.highlightCursor-1)
+      case key.Matches(msg, m.keys.Down):
+        m.highlightCursor = max(0, min(len(highlights)-1, m.highlightCursor+1))
+      case key.Matches(msg, m.keys.Select):
+        m.state = documentReadView
+
+        if len(highlights) > 0 {
+          if line, ok := m.highlightLines[highlights[m.highlightCursor].ID]; ok {
+            m.scrollOffset = min(line, m.maxScroll())
+          }
+        }
+      case key.Matches(msg, m.keys.Back, m.keys.Highlights):
+        m.state = documentReadView
+      }
+
+      return m, nil
+    }
+
+    if m.offline && m.state == documentListView && m.keys.requiresNetwork(msg) {
+      m.status = "Not available in offline mode"
+      return m, nil
+    }
+
+    // The document is resolved by ID rather than list position, since a sync
+    // can reorder or shrink the list while the prompt is open.
+    if m.deleteID != "" {
+      id := m.deleteID
+
+      m.deleteID = ""
+
+      switch {
+      case key.Matches(msg, m.keys.Confirm):
+        doc, ok := m.findDocument(id)
+
+        if !ok {
+          m.status = ""
+          return m, nil
+        }
+
+        m.removeDocument(doc.ID)
+        m.status = fmt.Sprintf("Deleting %s...", doc.Title)
+        return m, deleteDocument(m.ctx, m.api, m.cache, doc)
+      case msg.Type == tea.KeyCtrlC:
+        return m, m.quit()
+      }
+
+      m.status = ""
+
+      return m, nil
+    }
+
+    if location, ok := m.keys.moveLocation(msg); ok && m.state == documentListView {
+      if len(m.documents) > 0 {
+        doc := m.documents[m.selected]
+
+        if doc.Location == location {
+          return m, nil
+        }
+
+        previous := doc.Location
+        doc.Location = location
+
+        m.updateDocument(doc.ID, func(doc *Document) { doc.Location = location })
+        m.status = fmt.Sprintf("Moving to %s...", locationName(location))
+
+        return m, moveDocument(m.ctx, m.api, m.cache, doc, previous)
       }
-    case "k":
+
+      return m, nil
+    }
+
+    switch {
+    case key.Matches(msg, m.keys.Quit):
+      return m, m.quit()
+    case key.Matches(msg, m.keys.Up):
       if m.state == documentListView && len(m.documents) > 0 && m.selected > 0 {
         m.selected--
       } else if m.state == documentReadView && m.scrollOffset > 0 {
         m.scrollOffset--
       }
-    case "j":
+    case key.Matches(msg, m.keys.Down):
       if m.state == documentListView && len(m.documents) > 0 && m.selected < len(m.documents)-1 {
         m.selected++
-      } else if m.state == documentReadView && len(m.contentLines) > 0 {
-        maxScroll := len(m.contentLines) - (m.height - 6)
-        maxScroll = max(maxScroll, 0)
-        if m.scrollOffset < maxScroll {
-          m.scrollOffset++
-        }
+      } else if m.state == documentReadView && m.scrollOffset < m.maxScroll() {
+        m.scrollOffset++
       }
-    case "ctrl+u":
+    case key.Matches(msg, m.keys.PageUp):
       if m.state == documentListView && len(m.documents) > 0 {
         pageSize := max(1, (m.height-8)/2)
         m.selected = max(0, m.selected-pageSize)
@@ -168,127 +749,323 @@ func (m App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
         pageSize := max(1, (m.height-6)/2)
         m.scrollOffset = max(0, m.scrollOffset-pageSize)
       }
-    case "ctrl+d":
+    case key.Matches(msg, m.keys.PageDown):
       if m.state == documentListView && len(m.documents) > 0 {
         pageSize := max(1, (m.height-8)/2)
         m.selected = min(len(m.documents)-1, m.selected+pageSize)
-      } else if m.state == documentReadView && len(m.contentLines) > 0 {
+      } else if m.state == documentReadView {
         pageSize := max(1, (m.height-6)/2)
-        maxScroll := len(m.contentLines) - (m.height - 6)
-        maxScroll = max(maxScroll, 0)
-        m.scrollOffset = min(maxScroll, m.scrollOffset+pageSize)
+        m.scrollOffset = min(m.maxScroll(), m.scrollOffset+pageSize)
       }
-    case "left", "h":
-      if m.state == documentListView && len(m.categories) > 0 && m.selectedCategory > 0 {
+    case key.Matches(msg, m.keys.PrevCategory) && m.state == documentListView:
+      if len(m.categories) > 0 && m.selectedCategory > 0 {
         m.selectedCategory--
         m.currentLocation = m.categories[m.selectedCategory].Location
-        m.documents = m.filterDocumentsByLocation(m.currentLocation)
+        m.documents = m.visibleDocuments()
         m.selected = 0
       }
-    case "right", "l":
-      if m.state == documentListView && len(m.categories) > 0 && m.selectedCategory < len(m.categories)-1 {
+    case key.Matches(msg, m.keys.NextCategory) && m.state == documentListView:
+      if len(m.categories) > 0 && m.selectedCategory < len(m.categories)-1 {
         m.selectedCategory++
         m.currentLocation = m.categories[m.selectedCategory].Location
-        m.documents = m.filterDocumentsByLocation(m.currentLocation)
+        m.documents = m.visibleDocuments()
         m.selected = 0
       }
-    case "enter":
+    case key.Matches(msg, m.keys.PrevType, m.keys.NextType) && m.state == documentListView:
+      types := m.readerCategories()
+
+      i := slices.IndexFunc(types, func(t ReaderCategory) bool { return t.Category == m.currentType })
+
+      if key.Matches(msg, m.keys.PrevType) {
+        i = max(0, i-1)
+      } else {
+        i = min(len(types)-1, i+1)
+      }
+
+      m.currentType = types[i].Category
+      m.documents = m.visibleDocuments()
+      m.selected = 0
+    case key.Matches(msg, m.keys.Select):
       if m.state == documentListView && len(m.documents) > 0 {
         m.state = documentReadView
+        m.current = m.documents[m.selected]
         m.content = ""
+        m.links = nil
+        m.linkCursor = -1
         return m, loadDocumentContent(m.documents[m.selected])
+      } else if m.state == documentReadView && m.linkCursor >= 0 && m.linkCursor < len(m.links) {
+        return m, m.openExternal(m.config.browserCommand(), m.links[m.linkCursor].URL)
       }
-    case "esc", "backspace":
-      if m.state == documentReadView {
-        m.state = documentListView
-        m.content = ""
-        m.scrollOffset = 0
+    case key.Matches(msg, m.keys.NextLink, m.keys.PrevLink) && m.state == documentReadView:
+      if len(m.links) > 0 {
+        if key.Matches(msg, m.keys.NextLink) {
+          m.linkCursor = (m.linkCursor + 1) % len(m.links)
+        } else {
+          m.linkCursor = (max(m.linkCursor, 0) + len(m.links) - 1) % len(m.links)
+        }
+
+        m.scrollToLink()
       }
-    case "r":
-      if m.state == documentListView {
-        m.loading = true
-        m.err = nil
-        return m, loadAllDocuments(m.api)
+    case key.Matches(msg, m.keys.Links) && m.state == documentReadView:
+      if m.content != "" {
+        m.state = linksView
+        m.linkCursor = max(0, m.linkCursor)
       }
-    }
-  }
+    case key.Matches(msg, m.keys.OpenURL, m.keys.CopyURL):
+      doc, ok := m.focusedDocument()
 
-  return m, nil
-}
+      if !ok {
+        return m, nil
+      }
 
-func (m App) renderDocumentList() string {
-  s := "📚 Reader\n\n"
+      target := documentURL(doc)
+
+      if target == "" {
+        m.status = "Document has no URL"
+        return m, nil
+      }
+
+      if key.Matches(msg, m.keys.CopyURL) {
+        return m, copyToClipboard(target)
+      }
 
-  if len(m.categories) > 1 {
-    for i, category := range m.categories {
-      if i > 0 {
-        s += " | "
+      return m, m.openExternal(m.config.browserCommand(), target)
+    case key.Matches(msg, m.keys.SaveLink) && m.state == documentReadView:
+      if m.linkCursor >= 0 && m.linkCursor < len(m.links) {
+        cmd := m.saveSelectedLink()
+
+        return m, cmd
       }
-      if i == m.selectedCategory {
-        s += fmt.Sprintf("[%s (%d)]", category.Name, category.Count)
+    case key.Matches(msg, m.keys.Sort, m.keys.SortOrder) && m.state == documentListView:
+      if key.Matches(msg, m.keys.Sort) {
+        m.sortMode = nextSortMode(m.sortMode)
       } else {
-        s += fmt.Sprintf("%s (%d)", category.Name, category.Count)
+        m.sortDescending = !m.sortDescending
       }
-    }
-    s += "\n\n"
-  } else if len(m.categories) == 1 {
-    s += fmt.Sprintf("%s (%d)\n\n", m.categories[0].Name, m.categories[0].Count)
-  }
 
-  if m.err != nil {
-    s += fmt.Sprintf("Error: %s\n", m.err.Error())
-    s += "\nSet token: reader config set-token <token>\n"
-  } else if m.loading {
-    s += "Loading...\n"
-  } else if len(m.documents) == 0 {
-    s += "No documents found.\n"
-  } else {
-    maxVisible := m.height - 8
-    maxVisible = max(maxVisible, 5)
+      m.resortDocuments()
 
-    start := 0
-    end := len(m.documents)
+      m.config.Sort = m.sortMode
+      m.config.SortOrder = "asc"
 
-    if len(m.documents) > maxVisible {
-      start = m.selected - maxVisible/2
-      start = max(start, 0)
-      end = start + maxVisible
-      if end > len(m.documents) {
-        end = len(m.documents)
-        start = end - maxVisible
+      if m.sortDescending {
+        m.config.SortOrder = "desc"
       }
-    }
 
-    for i := start; i < end; i++ {
-      cursor := " "
+      return m, saveSortConfig(*m.config)
+    case key.Matches(msg, m.keys.Cancel) && m.state == documentListView:
+      if m.syncing {
+        m.cancelSync()
+        m.syncing = false
+        m.loading = false
+        m.status = "Sync cancelled"
+      } else if m.searchQuery != "" {
+        m.setSearchQuery("")
+      }
+    case key.Matches(msg, m.keys.Back) && m.state == documentReadView:
+      if m.linkCursor >= 0 {
+        m.linkCursor = -1
+      } else {
+        cmd := m.recordReadingProgress(m.ctx)
+
+        m.state = documentListView
+        m.content = ""
+        m.scrollOffset = 0
 
-      if i == m.selected {
-        cursor = ">"
+        return m, cmd
+      }
+    case key.Matches(msg, m.keys.Visual) && m.state == documentReadView:
+      if len(m.contentLines) > 0 {
+        m.selecting = true
+        m.selectionAnchor = min(m.scrollOffset, len(m.contentLines)-1)
+        m.selectionCursor = m.selectionAnchor
+        m.status = ""
+      }
+    case key.Matches(msg, m.keys.Images) && m.state == documentReadView:
+      if m.content != "" {
+        m.state = imagesView
+        m.imageCursor = 0
+      }
+    case key.Matches(msg, m.keys.Highlights) && m.state == documentReadView:
+      if m.content != "" {
+        m.state = highlightsView
+        m.highlightCursor = 0
       }
+    case key.Matches(msg, m.keys.Search) && m.state == documentListView:
+      m.searching = true
+    case key.Matches(msg, m.keys.Tags) && m.state == documentListView:
+      m.showTags = true
+      m.tagCursor = 0
 
-      s += fmt.Sprintf("%s %s\n", cursor, m.documents[i].Title)
-    }
+      for i, tag := range m.tagCounts {
+        if tag.Name == m.selectedTag {
+          m.tagCursor = i + 1
+        }
+      }
+    case key.Matches(msg, m.keys.EditTags) && m.state == documentListView:
+      if len(m.documents) > 0 {
+        m.editingTags = true
+        m.editingTagsID = m.documents[m.selected].ID
+        m.tagInput = strings.Join(m.documents[m.selected].Tags.Names(), ", ")
+      }
+    case key.Matches(msg, m.keys.Delete) && m.state == documentListView:
+      if len(m.documents) > 0 {
+        m.deleteID = m.documents[m.selected].ID
+        m.status = fmt.Sprintf("Delete %q? (%s, any other key to cancel)", m.documents[m.selected].Title, helpEntry("delete", m.keys.Confirm))
+      }
+    case key.Matches(msg, m.keys.Refresh) && m.state == documentListView:
+      cmd := m.sync(m.lastSync, "")
 
-    if len(m.documents) > maxVisible {
-      s += fmt.Sprintf("\n(%d/%d)", m.selected+1, len(m.documents))
+      return m, cmd
+    case key.Matches(msg, m.keys.FullRefresh) && m.state == documentListView:
+      cmd := m.sync(time.Time{}, "")
+
+      return m, cmd
+    case key.Matches(msg, m.keys.RefreshType) && m.state == documentListView:
+      cmd := m.sync(m.lastSync, m.currentType)
+
+      return m, cmd
     }
   }
 
-  helpText := "↑/↓ j/k move, enter read"
+  return m, nil
+}
 
-  if len(m.categories) > 1 {
-    helpText += ", ←/→ h/l switch category"
-  }
+func (m App) renderDocumentList() string {
+  s := styles.Title.Render("📚 Reader") + "\n\n"
 
-  helpText += ", r refresh, q quit"
+  types := m.readerCategories()
 
-  s += "\n\n" + helpText
+  showTypes := len(types) > 2 || m.currentType != ""
 
-  return s
+  if len(m.categories) > 0 {
+    s += m.renderTabs()
+
+    if showTypes {
+      s += "\n" + m.renderTypeTabs(types)
+    }
+
+    s += "\n\n"
+  }
+
+  direction := "↑"
+
+  if m.sortDescending {
+    direction = "↓"
+  }
+
+  filters := []string{fmt.Sprintf("Sort: %s %s", sortNames[m.sortMode], direction)}
+
+  if m.selectedTag != "" {
+    filters = append(filters, "Tag: #"+m.selectedTag)
+  }
+
+  if m.searching || m.searchQuery != "" {
+    cursor := ""
+
+    if m.searching {
+      cursor = "█"
+    }
+
+    filters = append(filters, fmt.Sprintf("/%s%s", m.searchQuery, cursor))
+  }
+
+  if len(filters) > 0 {
+    s += styles.Muted.Render(strings.Join(filters, "  ")) + "\n\n"
+  }
+
+  footer := ""
+
+  if m.editingTags {
+    footer += "\n\n" + m.renderStatusBar(fmt.Sprintf("Tags: %s█", m.tagInput))
+  } else if m.status != "" {
+    footer += "\n\n" + m.renderStatusBar(m.status)
+  }
+
+  footer += "\n\n" + styles.Muted.Render(m.keys.listHelp(len(m.categories) > 1, showTypes))
+
+  // The body shares its first line with the header and its last line with
+  // the footer, so it has one line more than the rest of the screen.
+  bodyHeight := m.height - renderedHeight(s+footer, m.width) + 1
+
+  sidebar := ""
+
+  if m.showTags {
+    sidebar = m.renderTagSidebar(bodyHeight)
+  }
+
+  body := ""
+
+  if m.err != nil {
+    body += fmt.Sprintf("Error: %s\n", m.err.Error())
+    body += "\nSet token: reader config set-token <token>\n"
+  } else if m.loading {
+    body += "Loading...\n"
+  } else if len(m.documents) == 0 {
+    body += "No documents found.\n"
+  } else {
+    // Besides the rows, the body has a column header, a blank line and the
+    // position.
+    maxVisible := max(bodyHeight-3, 1)
+
+    start := 0
+    end := len(m.documents)
+
+    if len(m.documents) > maxVisible {
+      start = m.selected - maxVisible/2
+      start = max(start, 0)
+      end = start + maxVisible
+      if end > len(m.documents) {
+        end = len(m.documents)
+        start = end - maxVisible
+      }
+    }
+
+    width := m.width
+
+    if m.showTags {
+      width -= lipgloss.Width(sidebar) + 2
+    }
+
+    // Rows are padded by a space on either side, see Styles.row.
+    columns := layoutColumns(m.columns, max(width, 40)-2)
+
+    body += " " + renderColumnHeader(columns) + "\n"
+
+    for i := start; i < end; i++ {
+      body += styles.row(renderColumns(m.documents[i], columns, m.searchQuery), i == m.selected) + "\n"
+    }
+
+    if len(m.documents) > maxVisible {
+      body += styles.Muted.Render(fmt.Sprintf("\n(%d/%d)", m.selected+1, len(m.documents)))
+    }
+  }
+
+  if m.showTags {
+    body = lipgloss.JoinHorizontal(lipgloss.Top, sidebar, "  ", body)
+  }
+
+  return s + body + footer
 }
 
 func (m App) renderDocument() string {
-  s := "📖 Reading\n\n"
+  s := styles.Title.Render("📖 Reading")
+
+  var metadata []string
+
+  if len(m.current.Tags) > 0 {
+    metadata = append(metadata, m.current.Tags.String())
+  }
+
+  if count := len(m.highlights[m.current.ID]); count > 0 {
+    metadata = append(metadata, fmt.Sprintf("(%d highlights)", count))
+  }
+
+  if len(metadata) > 0 {
+    s += "  " + styles.Muted.Render(strings.Join(metadata, "  "))
+  }
+
+  s += "\n\n"
 
   if m.content == "" {
     s += "Loading content..."
@@ -304,21 +1081,254 @@ func (m App) renderDocument() string {
     end := start + availableHeight
     end = min(end, len(m.contentLines))
 
+    selectionStart, selectionEnd := m.selectionRange()
+
     for i := start; i < end; i++ {
-      s += m.contentLines[i] + "\n"
+      if m.selecting && i >= selectionStart && i <= selectionEnd {
+        s += styles.Mark.Render(ansi.Strip(m.contentLines[i])) + "\n"
+      } else if m.linkCursor >= 0 {
+        s += markLink(m.contentLines[i], m.linkCursor+1) + "\n"
+      } else {
+        s += m.contentLines[i] + "\n"
+      }
     }
 
+    var footer []string
+
     if len(m.contentLines) > availableHeight {
       scrollPercent := float64(m.scrollOffset) / float64(len(m.contentLines)-availableHeight) * 100
-      s += fmt.Sprintf("\n[%.0f%%]", scrollPercent)
+      footer = append(footer, fmt.Sprintf("[%.0f%%]", scrollPercent))
+    }
+
+    if m.notingHighlight {
+      footer = append(footer, fmt.Sprintf("Note (optional): %s█", m.noteInput))
+    } else if m.selecting {
+      footer = append(footer, "-- VISUAL -- "+m.keys.visualHelp())
+    } else if m.status != "" {
+      footer = append(footer, m.status)
+    } else if m.linkCursor >= 0 && m.linkCursor < len(m.links) {
+      footer = append(footer, fmt.Sprintf("%s %s  %s, %s, %s", linkMarker(m.linkCursor+1), m.links[m.linkCursor].URL, helpEntry("open", m.keys.Select), helpEntry("save", m.keys.SaveLink), helpEntry("clear", m.keys.Back)))
+    }
+
+    if len(footer) > 0 {
+      s += "\n" + m.renderStatusBar(strings.Join(footer, "  "))
     }
   }
 
-  s += "\n\n↑/↓ j/k scroll, esc back, q quit"
+  s += "\n\n" + styles.Muted.Render(m.keys.readHelp())
 
   return s
 }
 
+func (m App) renderImages() string {
+  s := styles.Title.Render("🖼 Images: "+m.current.Title) + "\n\n"
+
+  if len(m.images) == 0 {
+    s += "No images in this document.\n"
+  }
+
+  availableHeight := max(m.height-6, 5)
+
+  start := max(0, min(m.imageCursor-availableHeight/2, len(m.images)-availableHeight))
+
+  for i := start; i < min(len(m.images), start+availableHeight); i++ {
+    label := m.images[i].Alt
+
+    if label == "" {
+      label = m.images[i].URL
+    }
+
+    s += styles.row(fmt.Sprintf("%d. %s", i+1, label), i == m.imageCursor) + "\n"
+  }
+
+  s += "\n" + styles.Muted.Render(m.keys.pickerHelp("open"))
+
+  return s
+}
+
+func (m App) renderLinks() string {
+  s := styles.Title.Render("🔗 Links: "+m.current.Title) + "\n\n"
+
+  if len(m.links) == 0 {
+    s += "No links in this document.\n"
+  }
+
+  availableHeight := max(m.height-8, 5)
+
+  start := max(0, min(m.linkCursor-availableHeight/2, len(m.links)-availableHeight))
+
+  for i := start; i < min(len(m.links), start+availableHeight); i++ {
+    label := m.links[i].Text
+
+    if label == "" {
+      label = m.links[i].URL
+    }
+
+    s += styles.row(fmt.Sprintf("%d. %s", i+1, label), i == m.linkCursor) + "\n"
+  }
+
+  if m.linkCursor >= 0 && m.linkCursor < len(m.links) {
+    s += "\n" + styles.Muted.Render(m.links[m.linkCursor].URL)
+  }
+
+  if m.status != "" {
+    s += "\n" + m.renderStatusBar(m.status)
+  }
+
+  s += "\n\n" + styles.Muted.Render(m.keys.pickerHelp("open", helpEntry("save to Reader", m.keys.SaveLink)))
+
+  return s
+}
+
+func (m App) renderHighlights() string {
+  highlights := m.highlights[m.current.ID]
+
+  s := styles.Title.Render("🖍 Highlights: "+m.current.Title) + "\n\n"
+
+  if len(highlights) == 0 {
+    s += "No highlights for this document.\n"
+  }
+
+  width := max(20, min(m.width, 100)-4)
+
+  availableHeight := max(m.height-6, 10)
+
+  used := 0
+
+  for i := max(0, m.highlightCursor-1); i < len(highlights); i++ {
+    cursor := " "
+
+    if i == m.highlightCursor {
+      cursor = styles.Cursor.Render("▌")
+    }
+
+    block := ""
+
+    text := lipgloss.NewStyle().Width(width).Render(strings.TrimSpace(highlights[i].Content))
+
+    for _, line := range strings.Split(text, "\n") {
+      block += cursor + " " + styles.Highlight.Render(line) + "\n"
+    }
+
+    if note := strings.TrimSpace(highlights[i].Notes); note != "" {
+      block += cursor + " " + styles.Muted.Render("Note: "+note) + "\n"
+    }
+
+    block += "\n"
+
+    lines := strings.Count(block, "\n")
+
+    if used > 0 && used+lines > availableHeight {
+      break
+    }
+
+    s += block
+    used += lines
+  }
+
+  if len(highlights) > 0 {
+    s += styles.Muted.Render(fmt.Sprintf("(%d/%d)", m.highlightCursor+1, len(highlights))) + "\n"
+  }
+
+  s += "\n" + styles.Muted.Render(m.keys.pickerHelp("jump to highlight"))
+
+  return s
+}
+
+// The sidebar scrolls to keep the cursor in view, and is as wide as its
+// widest tag so the list beside it doesn't shift while scrolling.
+func (m App) renderTagSidebar(height int) string {
+  entries := append([]TagCount{{Name: "All", Count: len(m.allDocuments)}}, m.tagCounts...)
+
+  labels := make([]string, len(entries))
+
+  width := 0
+
+  for i, tag := range entries {
+    name := tag.Name
+
+    if i > 0 {
+      name = "#" + name
+    }
+
+    labels[i] = fmt.Sprintf("%s (%d)", name, tag.Count)
+    width = max(width, lipgloss.Width(labels[i]))
+  }
+
+  lines := []string{styles.Title.Render("Tags"), ""}
+
+  availableHeight := max(height-len(lines), 1)
+
+  start := max(0, min(m.tagCursor-availableHeight/2, len(entries)-availableHeight))
+
+  for i := start; i < min(len(entries), start+availableHeight); i++ {
+    label := labels[i] + strings.Repeat(" ", width-lipgloss.Width(labels[i]))
+
+    lines = append(lines, styles.row(label, i == m.tagCursor))
+  }
+
+  return strings.Join(lines, "\n")
+}
+
+func (m App) renderTabs() string {
+  tabs := make([]string, len(m.categories))
+
+  for i, category := range m.categories {
+    label := fmt.Sprintf("%s (%d)", category.Name, category.Count)
+
+    if i == m.selectedCategory {
+      tabs[i] = styles.ActiveTab.Render(label)
+    } else {
+      tabs[i] = styles.Tab.Render(label)
+    }
+  }
+
+  return strings.Join(tabs, styles.Muted.Render(" │ "))
+}
+
+func (m App) renderTypeTabs(types []ReaderCategory) string {
+  tabs := make([]string, len(types))
+
+  for i, t := range types {
+    label := fmt.Sprintf("%s (%d)", t.Name, t.Count)
+
+    if t.Category == m.currentType {
+      tabs[i] = styles.ActiveTab.Render(label)
+    } else {
+      tabs[i] = styles.Tab.Render(label)
+    }
+  }
+
+  return strings.Join(tabs, styles.Muted.Render(" · "))
+}
+
+// Lines wider than the terminal wrap, so they take up more than one row.
+func renderedHeight(s string, width int) int {
+  height := 0
+
+  for _, line := range strings.Split(s, "\n") {
+    lineWidth := ansi.StringWidth(line)
+
+    if width <= 0 || lineWidth <= width {
+      height++
+    } else {
+      height += (lineWidth + width - 1) / width
+    }
+  }
+
+  return height
+}
+
+func (m App) renderStatusBar(text string) string {
+  text = " " + text + " "
+
+  if m.width <= 0 {
+    return styles.StatusBar.Render(text)
+  }
+
+  return styles.StatusBar.Width(m.width).Render(ansi.Truncate(text, m.width, "…"))
+}
+
 func (m App) filterDocumentsByLocation(location string) []Document {
   var filtered []Document
 
@@ -330,3 +1340,377 @@ func (m App) filterDocumentsByLocation(location string) []Document {
 
   return filtered
 }
+
+// Reader categories are counted within the current location, since that is
+// what the type filter narrows.
+func (m App) readerCategories() []ReaderCategory {
+  return buildReaderCategories(m.filterDocumentsByLocation(m.currentLocation), m.currentType)
+}
+
+func (m App) findDocument(id string) (Document, bool) {
+  for _, doc := range m.allDocuments {
+    if doc.ID == id {
+      return doc, true
+    }
+  }
+
+  return Document{}, false
+}
+
+func (m *App) updateDocument(id string, update func(doc *Document)) {
+  for i := range m.allDocuments {
+    if m.allDocuments[i].ID == id {
+      update(&m.allDocuments[i])
+      break
+    }
+  }
+
+  m.rebuildCategories()
+}
+
+func (m *App) removeDocument(id string) {
+  for i := range m.allDocuments {
+    if m.allDocuments[i].ID == id {
+      m.allDocuments = append(m.allDocuments[:i:i], m.allDocuments[i+1:]...)
+      break
+    }
+  }
+
+  m.rebuildCategories()
+}
+
+func (m *App) rebuildCategories() {
+  m.categories = buildCategories(m.allDocuments)
+  m.tagCounts = buildTagCounts(m.allDocuments)
+
+  if m.selectedTag != "" && !slices.ContainsFunc(m.tagCounts, func(tag TagCount) bool { return tag.Name == m.selectedTag }) {
+    m.selectedTag = ""
+  }
+
+  if len(m.categories) == 0 {
+    m.selectedCategory = 0
+    m.documents = nil
+    m.selected = 0
+    return
+  }
+
+  found := false
+
+  for i, category := range m.categories {
+    if category.Location == m.currentLocation {
+      m.selectedCategory = i
+      found = true
+      break
+    }
+  }
+
+  if !found {
+    m.selectedCategory = min(m.selectedCategory, len(m.categories)-1)
+    m.currentLocation = m.categories[m.selectedCategory].Location
+  }
+
+  m.documents = m.visibleDocuments()
+  m.selected = max(0, min(m.selected, len(m.documents)-1))
+}
+
+// Only refreshing the selected type syncs a single category. Every other sync
+// covers the whole library, so that it can move the last sync time forward.
+// Starting a sync cancels the one in flight, so refreshing again restarts it.
+func (m *App) sync(since time.Time, category string) tea.Cmd {
+  m.cancelSync()
+
+  ctx, cancel := context.WithCancel(m.ctx)
+
+  m.stopSync = cancel
+  m.err = nil
+  m.syncing = true
+  m.loading = len(m.allDocuments) == 0
+  m.status = "Syncing..."
+
+  if category != "" {
+    m.status = fmt.Sprintf("Syncing %s...", readerCategoryName(category))
+  }
+
+  return syncDocuments(ctx, m.api, m.cache, since, category)
+}
+
+func (m *App) cancelSync() {
+  if m.stopSync != nil {
+    m.stopSync()
+    m.stopSync = nil
+  }
+}
+
+// Leaving a document saves the reading position, so quitting from the reading
+// view waits briefly for that request before shutting down.
+func (m App) quit() tea.Cmd {
+  if m.state != documentListView {
+    ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+
+    if save := m.recordReadingProgress(ctx); save != nil {
+      return tea.Sequence(save, func() tea.Msg {
+        cancel()
+        m.cancel()
+
+        return tea.Quit()
+      })
+    }
+
+    cancel()
+  }
+
+  m.cancel()
+
+  return tea.Quit
+}
+
+func (m *App) recordReadingProgress(ctx context.Context) tea.Cmd {
+  if m.content == "" || m.current.ID == "" {
+    return nil
+  }
+
+  progress := 1.0
+
+  if maxScroll := m.maxScroll(); maxScroll > 0 {
+    progress = float64(m.scrollOffset) / float64(maxScroll)
+  }
+
+  if math.Abs(progress-m.current.ReadingProgress) < 0.01 {
+    return nil
+  }
+
+  m.current.ReadingProgress = progress
+  m.updateDocument(m.current.ID, func(doc *Document) { doc.ReadingProgress = progress })
+
+  doc := m.current
+
+  if updated, ok := m.findDocument(doc.ID); ok {
+    doc = updated
+  }
+
+  return saveReadingProgress(ctx, m.api, m.cache, doc)
+}
+
+func newRenderer(style string, width int) (*glamour.TermRenderer, error) {
+  return glamour.NewTermRenderer(
+    glamour.WithStylePath(style),
+    glamour.WithWordWrap(width),
+  )
+}
+
+func (m *App) renderContent() {
+  if rendered, err := m.renderer.Render(m.content); err == nil {
+    m.contentLines = strings.Split(rendered, "\n")
+  } else {
+    m.contentLines = strings.Split(m.content, "\n")
+  }
+
+  m.contentLines, m.highlightLines = applyHighlights(m.contentLines, m.highlights[m.current.ID])
+
+  if margin := strings.Repeat(" ", m.config.margin()); margin != "" {
+    for i, line := range m.contentLines {
+      m.contentLines[i] = margin + line
+    }
+  }
+}
+
+// Line positions are carried over in proportion to the document's length, so
+// the same passage stays in view when the text is wrapped to a new width.
+func (m *App) rerenderContent() {
+  previous := max(1, len(m.contentLines))
+
+  m.renderContent()
+
+  rescale := func(line int) int {
+    return max(0, min(len(m.contentLines)-1, int(math.Round(float64(line)*float64(len(m.contentLines))/float64(previous)))))
+  }
+
+  m.scrollOffset = min(rescale(m.scrollOffset), m.maxScroll())
+  m.selectionAnchor = rescale(m.selectionAnchor)
+  m.selectionCursor = rescale(m.selectionCursor)
+}
+
+func (m App) selectionRange() (int, int) {
+  return min(m.selectionAnchor, m.selectionCursor), max(m.selectionAnchor, m.selectionCursor)
+}
+
+func (m *App) moveSelection(delta int) {
+  m.selectionCursor = max(0, min(len(m.contentLines)-1, m.selectionCursor+delta))
+
+  availableHeight := max(m.height-6, 1)
+
+  if m.selectionCursor < m.scrollOffset {
+    m.scrollOffset = m.selectionCursor
+  } else if m.selectionCursor >= m.scrollOffset+availableHeight {
+    m.scrollOffset = min(m.maxScroll(), m.selectionCursor-availableHeight+1)
+  }
+}
+
+func (m App) openExternal(command, target string) tea.Cmd {
+  cmd, err := openCommand(command, target)
+
+  if err != nil {
+    return func() tea.Msg {
+      return externalCommandFinishedMsg{err: err}
+    }
+  }
+
+  return tea.ExecProcess(cmd, func(err error) tea.Msg {
+    return externalCommandFinishedMsg{err: err}
+  })
+}
+
+func (m App) focusedDocument() (Document, bool) {
+  switch {
+  case m.state == documentReadView:
+    return m.current, true
+  case m.state == documentListView && len(m.documents) > 0:
+    return m.documents[m.selected], true
+  default:
+    return Document{}, false
+  }
+}
+
+func (m *App) scrollToLink() {
+  if m.linkCursor < 0 {
+    return
+  }
+
+  line := findLinkLine(m.contentLines, m.linkCursor+1)
+
+  availableHeight := max(m.height-6, 1)
+
+  if line >= 0 && (line < m.scrollOffset || line >= m.scrollOffset+availableHeight) {
+    m.scrollOffset = min(max(0, line-availableHeight/2), m.maxScroll())
+  }
+}
+
+func (m *App) saveSelectedLink() tea.Cmd {
+  if m.offline {
+    m.status = "Not available in offline mode"
+    return nil
+  }
+
+  link := m.links[m.linkCursor]
+
+  m.status = fmt.Sprintf("Saving %s...", link.URL)
+
+  return saveLink(m.ctx, m.api, link)
+}
+
+func (m App) maxScroll() int {
+  return max(len(m.contentLines)-(m.height-6), 0)
+}
+
+func listedDocuments(documents []Document) []Document {
+  filtered := make([]Document, 0, len(documents))
+
+  for _, doc := range documents {
+    if strings.TrimSpace(doc.Title) != "" && !isHighlight(doc) {
+      filtered = append(filtered, doc)
+    }
+  }
+
+  return filtered
+}
+
+func (m *App) setHighlights(documents []Document) {
+  m.highlightDocs = nil
+
+  for _, doc := range documents {
+    if isHighlight(doc) {
+      m.highlightDocs = append(m.highlightDocs, doc)
+    }
+  }
+
+  m.highlights = groupHighlights(m.highlightDocs)
+}
+
+func (m App) visibleDocuments() []Document {
+  documents := m.filterDocumentsByLocation(m.currentLocation)
+
+  if m.currentType != "" {
+    var typed []Document
+
+    for _, doc := range documents {
+      if doc.Category == m.currentType {
+        typed = append(typed, doc)
+      }
+    }
+
+    documents = typed
+  }
+
+  if m.selectedTag != "" {
+    var tagged []Document
+
+    for _, doc := range documents {
+      if doc.Tags.Has(m.selectedTag) {
+        tagged = append(tagged, doc)
+      }
+    }
+
+    documents = tagged
+  }
+
+  if strings.TrimSpace(m.searchQuery) == "" {
+    sortDocuments(documents, m.sortMode, m.sortDescending)
+
+    return documents
+  }
+
+  var matches map[string]bool
+
+  if m.searchIndex != nil {
+    matches = m.searchIndex.Search(m.searchQuery)
+  }
+
+  var filtered []Document
+
+  for _, doc := range documents {
+    if matches[doc.ID] || (m.searchIndex == nil && matchesDocument(doc, m.searchQuery)) {
+      filtered = append(filtered, doc)
+    }
+  }
+
+  sortDocuments(filtered, m.sortMode, m.sortDescending)
+
+  return filtered
+}
+
+// The selected document stays selected when the order changes underneath it.
+func (m *App) resortDocuments() {
+  var selectedID string
+
+  if m.selected < len(m.documents) {
+    selectedID = m.documents[m.selected].ID
+  }
+
+  m.documents = m.visibleDocuments()
+
+  for i, doc := range m.documents {
+    if doc.ID == selectedID {
+      m.selected = i
+      break
+    }
+  }
+}
+
+func (m *App) setSearchQuery(query string) {
+  m.searchQuery = query
+  m.documents = m.visibleDocuments()
+  m.selected = 0
+}
+
+func editLine(value string, msg tea.KeyMsg) string {
+  switch msg.Type {
+  case tea.KeyBackspace:
+    if runes := []rune(value); len(runes) > 0 {
+      return string(runes[:len(runes)-1])
+    }
+  case tea.KeyRunes, tea.KeySpace:
+    return value + string(msg.Runes)
+  }
+
+  return value
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+  "context"
+  "fmt"
+  tea "github.com/charmbracelet/bubbletea"
+  "github.com/charmbracelet/lipgloss"
+  "strings"
+  "testing"
+)
+
+func TestRenderDocumentListFitsHeight(t *testing.T) {
+  var docs []Document
+
+  for i := range 100 {
+    category := "article"
+
+    if i%2 == 0 {
+      category = "rss"
+    }
+
+    if i%3 == 0 {
+      category = "pdf"
+    }
+
+    docs = append(docs, Document{ID: fmt.Sprint(i), Title: fmt.Sprintf("Document %d", i), Category: category, Location: "new"})
+  }
+
+  columns, err := listColumns(nil)
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  keys, err := newKeyMap(nil)
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  tests := []struct {
+    name  string
+    setup func(*App)
+  }{
+    {"plain", func(m *App) {}},
+    {"status", func(m *App) { m.status = "Synced" }},
+    {"categories", func(m *App) {
+      m.categories = []Category{{Name: "Inbox", Count: 100, Location: "new"}, {Name: "Later", Location: "later"}}
+    }},
+    {"tag sidebar", func(m *App) {
+      m.showTags = true
+      m.tagCounts = testTagCounts(60)
+      m.tagCursor = 40
+    }},
+    {"narrow with a long status", func(m *App) {
+      m.width = 40
+      m.categories = []Category{{Name: "Inbox", Count: 100, Location: "new"}, {Name: "Later", Location: "later"}}
+      m.status = strings.Repeat("status ", 20)
+    }},
+  }
+
+  for _, test := range tests {
+    for _, height := range []int{25, 40} {
+      t.Run(fmt.Sprintf("%s/%d", test.name, height), func(t *testing.T) {
+        m := App{
+          allDocuments:    docs,
+          columns:         columns,
+          currentLocation: "new",
+          documents:       docs,
+          height:          height,
+          keys:            keys,
+          selected:        50,
+          width:           120,
+        }
+
+        test.setup(&m)
+
+        view := m.renderDocumentList()
+
+        if got := renderedHeight(view, m.width); got != height {
+          t.Errorf("expected the list to fill %d lines, got %d:\n%s", height, got, view)
+        }
+
+        if lipgloss.Height(view) > height {
+          t.Errorf("expected at most %d lines, got %d", height, lipgloss.Height(view))
+        }
+      })
+    }
+  }
+}
+
+func TestDocumentMoveFailedRollsBack(t *testing.T) {
+  tests := []struct {
+    name     string
+    location string
+    expected string
+  }{
+    {"restores the previous location", "archive", "new"},
+    {"keeps a later move", "later", "later"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      m := App{allDocuments: []Document{{ID: "a", Location: test.location}}}
+
+      model, _ := m.Update(documentMoveFailedMsg{id: "a", location: "archive", previous: "new", err: errDocumentNotFound})
+
+      m = model.(App)
+
+      if doc, _ := m.findDocument("a"); doc.Location != test.expected {
+        t.Errorf("expected location %q, got %q", test.expected, doc.Location)
+      }
+
+      if !strings.Contains(m.status, "Failed to move document") {
+        t.Errorf("expected the failure in the status, got %q", m.status)
+      }
+    })
+  }
+}
+
+func testTagCounts(n int) []TagCount {
+  tags := make([]TagCount, n)
+
+  for i := range tags {
+    tags[i] = TagCount{Name: fmt.Sprintf("tag%02d", i), Count: 1}
+  }
+
+  return tags
+}
+
+func TestRenderTagSidebarScrolls(t *testing.T) {
+  for _, cursor := range []int{0, 30, 60} {
+    m := App{tagCounts: testTagCounts(60), tagCursor: cursor}
+
+    sidebar := m.renderTagSidebar(20)
+
+    if height := lipgloss.Height(sidebar); height != 20 {
+      t.Errorf("expected the sidebar to fill 20 lines with the cursor at %d, got %d", cursor, height)
+    }
+
+    selected := "All (0)"
+
+    if cursor > 0 {
+      selected = fmt.Sprintf("#tag%02d (1)", cursor-1)
+    }
+
+    if !strings.Contains(sidebar, selected) {
+      t.Errorf("expected %q to be visible with the cursor at %d:\n%s", selected, cursor, sidebar)
+    }
+  }
+}
+
+func TestRefreshRestartsSync(t *testing.T) {
+  keys, err := newKeyMap(nil)
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  for _, name := range []string{"r", "R", "ctrl+r"} {
+    t.Run(name, func(t *testing.T) {
+      running, stop := context.WithCancel(context.Background())
+
+      defer stop()
+
+      m := App{
+        ctx:      context.Background(),
+        keys:     keys,
+        state:    documentListView,
+        stopSync: stop,
+        syncing:  true,
+      }
+
+      msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+
+      if name == "ctrl+r" {
+        msg = tea.KeyMsg{Type: tea.KeyCtrlR}
+      }
+
+      model, cmd := m.Update(msg)
+
+      m = model.(App)
+
+      if running.Err() == nil {
+        t.Error("expected the running sync to be cancelled")
+      }
+
+      if cmd == nil || m.stopSync == nil || !m.syncing {
+        t.Error("expected a new sync to start")
+      }
+    })
+  }
+}
+
+func TestDocumentContentForAnotherDocumentIsDropped(t *testing.T) {
+  renderer, err := newRenderer("dark", 80)
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  m := App{
+    config:   &Config{},
+    current:  Document{ID: "b"},
+    renderer: renderer,
+    state:    documentReadView,
+  }
+
+  model, _ := m.Update(documentContentMsg{id: "a", content: "Content of A", links: []ContentLink{{URL: "https://example.com/a"}}})
+
+  m = model.(App)
+
+  if m.content != "" || len(m.links) != 0 {
+    t.Fatalf("expected A's content to be dropped, got %q with links %v", m.content, m.links)
+  }
+
+  model, _ = m.Update(documentContentMsg{id: "b", content: "Content of B"})
+
+  m = model.(App)
+
+  if m.content != "Content of B" {
+    t.Errorf("expected B's content, got %q", m.content)
+  }
+}
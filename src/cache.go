@@ -0,0 +1,184 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+type DocumentCache struct {
+  dir string
+}
+
+type cacheWriteFailedMsg struct {
+  err error
+}
+
+func openDocumentCache() (*DocumentCache, error) {
+  configDir, err := getConfigDir()
+
+  if err != nil {
+    return nil, err
+  }
+
+  cacheDir := filepath.Join(configDir, "cache", "documents")
+
+  if err := os.MkdirAll(cacheDir, 0700); err != nil {
+    return nil, fmt.Errorf("failed to create cache directory: %w", err)
+  }
+
+  return &DocumentCache{dir: cacheDir}, nil
+}
+
+// Document IDs come from the server and become file names, so anything that
+// could point outside the cache directory is rejected.
+func validCacheID(id string) bool {
+  return id != "" && !strings.ContainsAny(id, `/\`) && !strings.Contains(id, "..")
+}
+
+func (c *DocumentCache) path(id string) (string, error) {
+  if !validCacheID(id) {
+    return "", fmt.Errorf("invalid document id '%s'", id)
+  }
+
+  return filepath.Join(c.dir, id+".json"), nil
+}
+
+func (c *DocumentCache) Load() ([]Document, error) {
+  entries, err := os.ReadDir(c.dir)
+
+  if err != nil {
+    return nil, fmt.Errorf("failed to read cache directory: %w", err)
+  }
+
+  documents := make([]Document, 0, len(entries))
+
+  for _, entry := range entries {
+    if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+      continue
+    }
+
+    data, err := os.ReadFile(filepath.Join(c.dir, entry.Name()))
+
+    if err != nil {
+      return nil, fmt.Errorf("failed to read cached document: %w", err)
+    }
+
+    var doc Document
+
+    if err := json.Unmarshal(data, &doc); err != nil {
+      return nil, fmt.Errorf("failed to parse cached document %s: %w", entry.Name(), err)
+    }
+
+    documents = append(documents, doc)
+  }
+
+  return documents, nil
+}
+
+func (c *DocumentCache) Put(documents ...Document) error {
+  for _, doc := range documents {
+    if doc.ID == "" {
+      continue
+    }
+
+    path, err := c.path(doc.ID)
+
+    if err != nil {
+      return err
+    }
+
+    data, err := json.Marshal(doc)
+
+    if err != nil {
+      return fmt.Errorf("failed to marshal document %s: %w", doc.ID, err)
+    }
+
+    // Write to a temporary file first so an interrupted sync never leaves a
+    // truncated document behind.
+    tmpPath := path + ".tmp"
+
+    if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+      return fmt.Errorf("failed to write cache file: %w", err)
+    }
+
+    if err := os.Rename(tmpPath, path); err != nil {
+      return fmt.Errorf("failed to write cache file: %w", err)
+    }
+  }
+
+  return nil
+}
+
+func (c *DocumentCache) Delete(id string) error {
+  path, err := c.path(id)
+
+  if err != nil {
+    return err
+  }
+
+  if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+    return fmt.Errorf("failed to remove cached document: %w", err)
+  }
+
+  return nil
+}
+
+func (c *DocumentCache) Replace(documents []Document) error {
+  if err := c.Put(documents...); err != nil {
+    return err
+  }
+
+  keep := make(map[string]bool, len(documents))
+
+  for _, doc := range documents {
+    keep[doc.ID+".json"] = true
+  }
+
+  entries, err := os.ReadDir(c.dir)
+
+  if err != nil {
+    return fmt.Errorf("failed to read cache directory: %w", err)
+  }
+
+  // Highlights created locally are never returned by the server.
+  for _, entry := range entries {
+    if !keep[entry.Name()] && !strings.HasPrefix(entry.Name(), localHighlightPrefix) {
+      if err := os.RemoveAll(filepath.Join(c.dir, entry.Name())); err != nil {
+        return fmt.Errorf("failed to remove cached document: %w", err)
+      }
+    }
+  }
+
+  return nil
+}
+
+func (c *DocumentCache) ReplaceCategory(category string, documents []Document) error {
+  existing, err := c.Load()
+
+  if err != nil {
+    return err
+  }
+
+  if err := c.Put(documents...); err != nil {
+    return err
+  }
+
+  keep := make(map[string]bool, len(documents))
+
+  for _, doc := range documents {
+    keep[doc.ID] = true
+  }
+
+  for _, doc := range existing {
+    if doc.Category == category && !keep[doc.ID] && !isLocalHighlight(doc) {
+      if err := c.Delete(doc.ID); err != nil {
+        return err
+      }
+    }
+  }
+
+  return nil
+}
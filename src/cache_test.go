@@ -0,0 +1,79 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "slices"
+  "testing"
+)
+
+func TestValidCacheID(t *testing.T) {
+  tests := []struct {
+    id    string
+    valid bool
+  }{
+    {"01h8x2y4k5m6n7p8q9r0s1t2u3", true},
+    {"doc-1", true},
+    {"", false},
+    {"..", false},
+    {"../config", false},
+    {"a/b", false},
+    {`a\b`, false},
+  }
+
+  for _, test := range tests {
+    if valid := validCacheID(test.id); valid != test.valid {
+      t.Errorf("validCacheID(%q) = %v, want %v", test.id, valid, test.valid)
+    }
+  }
+}
+
+func TestDocumentCacheRejectsUnsafeIDs(t *testing.T) {
+  dir := t.TempDir()
+
+  cache := &DocumentCache{dir: filepath.Join(dir, "documents")}
+
+  if err := os.Mkdir(cache.dir, 0700); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := cache.Put(Document{ID: "../escaped"}); err == nil {
+    t.Error("Put accepted an id outside the cache directory")
+  }
+
+  if _, err := os.Stat(filepath.Join(dir, "escaped.json")); !os.IsNotExist(err) {
+    t.Error("Put wrote outside the cache directory")
+  }
+
+  if err := cache.Delete("../escaped"); err == nil {
+    t.Error("Delete accepted an id outside the cache directory")
+  }
+}
+
+func TestDocumentCacheReplaceKeepsLocalHighlights(t *testing.T) {
+  cache := &DocumentCache{dir: t.TempDir()}
+
+  local := Document{ID: localHighlightPrefix + "1", Category: "highlight", ParentID: "a"}
+
+  if err := cache.Put(Document{ID: "a"}, Document{ID: "stale"}, local); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := cache.Replace([]Document{{ID: "a"}, {ID: "b"}}); err != nil {
+    t.Fatal(err)
+  }
+
+  docs, err := cache.Load()
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  ids := documentIDs(docs)
+
+  slices.Sort(ids)
+
+  if want := []string{"a", "b", local.ID}; !slices.Equal(ids, want) {
+    t.Errorf("cached ids = %v, want %v", ids, want)
+  }
+}
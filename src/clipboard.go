@@ -0,0 +1,49 @@
+package main
+
+import (
+  "fmt"
+  "github.com/atotto/clipboard"
+  osc52 "github.com/aymanbagabas/go-osc52/v2"
+  tea "github.com/charmbracelet/bubbletea"
+  "os"
+  "strings"
+)
+
+type clipboardCopiedMsg struct {
+  text string
+}
+
+type clipboardCopyFailedMsg struct {
+  err error
+}
+
+func isRemoteSession() bool {
+  return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// The system clipboard belongs to the remote machine in an SSH session, so
+// the text is sent to the local terminal as an OSC52 sequence instead. The
+// sequence is also the fallback when no clipboard utility is installed.
+func copyToClipboard(text string) tea.Cmd {
+  return func() tea.Msg {
+    if !isRemoteSession() && !clipboard.Unsupported {
+      if err := clipboard.WriteAll(text); err == nil {
+        return clipboardCopiedMsg{text: text}
+      }
+    }
+
+    sequence := osc52.New(text)
+
+    if os.Getenv("TMUX") != "" {
+      sequence = sequence.Tmux()
+    } else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+      sequence = sequence.Screen()
+    }
+
+    if _, err := sequence.WriteTo(os.Stderr); err != nil {
+      return clipboardCopyFailedMsg{err: fmt.Errorf("failed to write to terminal: %w", err)}
+    }
+
+    return clipboardCopiedMsg{text: text}
+  }
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+  "fmt"
+  "github.com/mattn/go-runewidth"
+  "math"
+  "sort"
+  "strings"
+  "time"
+)
+
+type Column struct {
+  Name  string
+  Title string
+  Width int
+  Right bool
+  Value func(Document) string
+}
+
+const (
+  columnGap     = 2
+  minTitleWidth = 20
+  wordsPerMin   = 238
+)
+
+// Tags come right after the title, since they are the main way to narrow the
+// list, so narrow terminals drop the other columns first.
+var defaultColumns = []string{"type", "title", "tags", "author", "site", "reading_time", "saved", "progress"}
+
+var documentColumns = map[string]Column{
+  "type": {
+    Width: 2,
+    Value: func(doc Document) string { return readerCategoryIcon(doc.Category) },
+  },
+  "title": {
+    Title: "Title",
+    Value: func(doc Document) string { return doc.Title },
+  },
+  "author": {
+    Title: "Author",
+    Width: 18,
+    Value: func(doc Document) string { return doc.Author },
+  },
+  "site": {
+    Title: "Site",
+    Width: 18,
+    Value: func(doc Document) string {
+      if doc.SiteName != "" {
+        return doc.SiteName
+      }
+
+      return documentSite(doc)
+    },
+  },
+  "words": {
+    Title: "Words",
+    Width: 7,
+    Right: true,
+    Value: func(doc Document) string {
+      if doc.WordCount == 0 {
+        return ""
+      }
+
+      return formatCount(doc.WordCount)
+    },
+  },
+  "reading_time": {
+    Title: "Time",
+    Width: 7,
+    Right: true,
+    Value: func(doc Document) string {
+      if doc.ReadingTime != "" {
+        return doc.ReadingTime
+      }
+
+      if doc.WordCount == 0 {
+        return ""
+      }
+
+      return fmt.Sprintf("%d min", max(1, int(math.Ceil(float64(doc.WordCount)/wordsPerMin))))
+    },
+  },
+  "saved": {
+    Title: "Saved",
+    Width: 10,
+    Value: func(doc Document) string {
+      return formatDate(doc.SavedAt, time.Now())
+    },
+  },
+  "opened": {
+    Title: "Opened",
+    Width: 10,
+    Value: func(doc Document) string {
+      return formatDate(doc.LastOpenedAt, time.Now())
+    },
+  },
+  "progress": {
+    Title: "Read",
+    Width: 4,
+    Right: true,
+    Value: func(doc Document) string {
+      if doc.ReadingProgress <= 0 {
+        return ""
+      }
+
+      return fmt.Sprintf("%.0f%%", doc.ReadingProgress*100)
+    },
+  },
+  "tags": {
+    Title: "Tags",
+    Width: 20,
+    Value: func(doc Document) string { return doc.Tags.String() },
+  },
+}
+
+func listColumns(names []string) ([]Column, error) {
+  if len(names) == 0 {
+    names = defaultColumns
+  }
+
+  columns := make([]Column, 0, len(names))
+
+  for _, name := range names {
+    column, ok := documentColumns[name]
+
+    if !ok {
+      known := make([]string, 0, len(documentColumns))
+
+      for name := range documentColumns {
+        known = append(known, name)
+      }
+
+      sort.Strings(known)
+
+      return nil, fmt.Errorf("unknown column '%s': expected one of %s", name, strings.Join(known, ", "))
+    }
+
+    column.Name = name
+    columns = append(columns, column)
+  }
+
+  return columns, nil
+}
+
+func formatCount(count int) string {
+  if count < 1000 {
+    return fmt.Sprintf("%d", count)
+  }
+
+  return fmt.Sprintf("%.1fk", float64(count)/1000)
+}
+
+func formatDate(value Timestamp, now time.Time) string {
+  if value.IsZero() {
+    return ""
+  }
+
+  parsed := value.Local()
+
+  if parsed.Year() == now.Year() {
+    return parsed.Format("Jan 2")
+  }
+
+  return parsed.Format("2006-01-02")
+}
+
+// Fixed columns are dropped from the right until the title column has room,
+// so narrow terminals keep the title readable rather than every column
+// squeezed. The title takes whatever width is left over.
+func layoutColumns(columns []Column, width int) []Column {
+  fitted := append([]Column(nil), columns...)
+
+  for {
+    fixed := 0
+
+    flexible := -1
+
+    for i, column := range fitted {
+      if i > 0 {
+        fixed += columnGap
+      }
+
+      if column.Width == 0 && flexible < 0 {
+        flexible = i
+      } else {
+        fixed += column.Width
+      }
+    }
+
+    if flexible < 0 {
+      return fitted
+    }
+
+    if width-fixed >= minTitleWidth || len(fitted) == 1 {
+      fitted[flexible].Width = max(1, width-fixed)
+      return fitted
+    }
+
+    last := len(fitted) - 1
+
+    if last == flexible {
+      last--
+    }
+
+    fitted = append(fitted[:last], fitted[last+1:]...)
+  }
+}
+
+func fitCell(text string, column Column) string {
+  text = runewidth.Truncate(strings.Join(strings.Fields(text), " "), column.Width, "…")
+
+  if column.Right {
+    return runewidth.FillLeft(text, column.Width)
+  }
+
+  return runewidth.FillRight(text, column.Width)
+}
+
+func renderColumnHeader(columns []Column) string {
+  cells := make([]string, len(columns))
+
+  for i, column := range columns {
+    cells[i] = fitCell(column.Title, column)
+  }
+
+  return styles.Muted.Render(strings.Join(cells, strings.Repeat(" ", columnGap)))
+}
+
+func renderColumns(doc Document, columns []Column, query string) string {
+  cells := make([]string, len(columns))
+
+  for i, column := range columns {
+    cell := fitCell(column.Value(doc), column)
+
+    if column.Name == "title" {
+      cells[i] = highlightMatches(cell, query)
+    } else {
+      cells[i] = styles.Muted.Render(cell)
+    }
+  }
+
+  return strings.Join(cells, strings.Repeat(" ", columnGap))
+}
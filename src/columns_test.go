@@ -0,0 +1,106 @@
+package main
+
+import (
+  "github.com/charmbracelet/x/ansi"
+  "slices"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestLayoutColumns(t *testing.T) {
+  columns, err := listColumns([]string{"type", "title", "author", "saved"})
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  tests := []struct {
+    name   string
+    width  int
+    names  []string
+    widths []int
+  }{
+    {
+      name:   "wide",
+      width:  100,
+      names:  []string{"type", "title", "author", "saved"},
+      widths: []int{2, 100 - 2 - 18 - 10 - 3*columnGap, 18, 10},
+    },
+    {
+      name:   "drops columns from the right",
+      width:  50,
+      names:  []string{"type", "title", "author"},
+      widths: []int{2, 50 - 2 - 18 - 2*columnGap, 18},
+    },
+    {
+      name:   "keeps the title when nothing fits",
+      width:  10,
+      names:  []string{"title"},
+      widths: []int{10},
+    },
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      fitted := layoutColumns(columns, test.width)
+
+      var names []string
+
+      var widths []int
+
+      for _, column := range fitted {
+        names = append(names, column.Name)
+        widths = append(widths, column.Width)
+      }
+
+      if !slices.Equal(names, test.names) || !slices.Equal(widths, test.widths) {
+        t.Errorf("expected %v %v, got %v %v", test.names, test.widths, names, widths)
+      }
+    })
+  }
+
+  if columns[1].Width != 0 {
+    t.Errorf("expected the configured columns to be left unchanged, got title width %d", columns[1].Width)
+  }
+}
+
+func TestFormatDate(t *testing.T) {
+  now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+
+  tests := []struct {
+    name     string
+    value    Timestamp
+    expected string
+  }{
+    {"zero", Timestamp{}, ""},
+    {"this year", Timestamp{time.Date(2024, 3, 5, 9, 0, 0, 0, time.Local)}, "Mar 5"},
+    {"earlier year", Timestamp{time.Date(2023, 12, 31, 9, 0, 0, 0, time.Local)}, "2023-12-31"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      if got := formatDate(test.value, now); got != test.expected {
+        t.Errorf("expected %q, got %q", test.expected, got)
+      }
+    })
+  }
+}
+
+func TestDefaultColumnsShowTags(t *testing.T) {
+  columns, err := listColumns(nil)
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  doc := Document{Title: "Title", Tags: Tags{"go": {Name: "go"}}}
+
+  for _, width := range []int{60, 120} {
+    row := ansi.Strip(renderColumns(doc, layoutColumns(columns, width), ""))
+
+    if !strings.Contains(row, doc.Tags.String()) {
+      t.Errorf("expected the tags in a %d column row, got %q", width, row)
+    }
+  }
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "io"
+  "net/url"
+  "os"
+  "slices"
+  "strings"
+)
+
+var saveLocations = []string{"new", "later", "archive", "feed"}
+
+func parseCommandFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+  var positional []string
+
+  for {
+    if err := flags.Parse(args); err != nil {
+      return nil, err
+    }
+
+    args = flags.Args()
+
+    if len(args) == 0 {
+      break
+    }
+
+    positional = append(positional, args[0])
+    args = args[1:]
+  }
+
+  return positional, nil
+}
+
+func splitTags(tags string) []string {
+  var result []string
+
+  for _, tag := range strings.Split(tags, ",") {
+    if tag = strings.TrimSpace(tag); tag != "" {
+      result = append(result, tag)
+    }
+  }
+
+  return result
+}
+
+func validateDocumentURL(rawURL string) error {
+  parsed, err := url.ParseRequestURI(rawURL)
+
+  if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+    return fmt.Errorf("invalid URL '%s': expected an absolute http(s) URL", rawURL)
+  }
+
+  return nil
+}
+
+func newCommandAPI() (*ReaderAPI, error) {
+  token, err := getToken()
+
+  if err != nil {
+    return nil, err
+  }
+
+  api := NewReaderAPI(token)
+
+  api.onRetry = func(notice RetryNotice) {
+    fmt.Fprintln(os.Stderr, notice.String())
+  }
+
+  return api, nil
+}
+
+func saveCommand(ctx context.Context, args []string) error {
+  flags := flag.NewFlagSet("save", flag.ContinueOnError)
+
+  flags.SetOutput(io.Discard)
+
+  title := flags.String("title", "", "")
+  author := flags.String("author", "", "")
+  tags := flags.String("tags", "", "")
+  location := flags.String("location", "", "")
+
+  positional, err := parseCommandFlags(flags, args)
+
+  if err != nil {
+    return err
+  }
+
+  if len(positional) != 1 {
+    return fmt.Errorf("save requires exactly one URL argument")
+  }
+
+  documentURL := positional[0]
+
+  if err := validateDocumentURL(documentURL); err != nil {
+    return err
+  }
+
+  if *location != "" && !slices.Contains(saveLocations, *location) {
+    return fmt.Errorf("invalid location '%s': expected one of %s", *location, strings.Join(saveLocations, ", "))
+  }
+
+  api, err := newCommandAPI()
+
+  if err != nil {
+    return err
+  }
+
+  resp, err := api.SaveDocument(ctx, SaveDocumentRequest{
+    URL:        documentURL,
+    Author:     *author,
+    Location:   *location,
+    SavedUsing: "reader-tui",
+    Tags:       splitTags(*tags),
+    Title:      *title,
+  })
+
+  if errors.Is(err, errDocumentExists) {
+    return fmt.Errorf("'%s' is already saved as document %s (%s)", documentURL, resp.ID, resp.URL)
+  }
+
+  if err != nil {
+    return err
+  }
+
+  fmt.Printf("%s\n%s\n", resp.ID, resp.URL)
+
+  return nil
+}
+
+func deleteCommand(ctx context.Context, args []string) error {
+  if len(args) == 0 {
+    return fmt.Errorf("delete requires at least one document ID")
+  }
+
+  api, err := newCommandAPI()
+
+  if err != nil {
+    return err
+  }
+
+  failed := 0
+
+  for _, id := range args {
+    if err := api.DeleteDocument(ctx, id); err != nil {
+      fmt.Fprintf(os.Stderr, "failed to delete %s: %s\n", id, err.Error())
+      failed++
+      continue
+    }
+
+    fmt.Printf("Deleted %s\n", id)
+  }
+
+  if failed > 0 {
+    return fmt.Errorf("%d of %d documents could not be deleted", failed, len(args))
+  }
+
+  return nil
+}
+
+func checkTokenCommand(ctx context.Context) error {
+  token, source, err := getTokenSource()
+
+  if err != nil {
+    return err
+  }
+
+  if err := NewReaderAPI(token).ValidateToken(ctx); err != nil {
+    return fmt.Errorf("token from %s is not valid: %w", source, err)
+  }
+
+  fmt.Printf("Token from %s is valid\n", source)
+
+  return nil
+}
+
+func highlightsCommand(ctx context.Context, args []string) error {
+  flags := flag.NewFlagSet("highlights", flag.ContinueOnError)
+
+  flags.SetOutput(io.Discard)
+
+  format := flags.String("format", "markdown", "")
+
+  positional, err := parseCommandFlags(flags, args)
+
+  if err != nil {
+    return err
+  }
+
+  if len(positional) != 1 {
+    return fmt.Errorf("highlights requires exactly one document ID")
+  }
+
+  api, err := newCommandAPI()
+
+  if err != nil {
+    return err
+  }
+
+  docs, err := api.GetDocuments(ctx, DocumentListOptions{ID: positional[0]})
+
+  if err != nil {
+    return err
+  }
+
+  if len(docs) == 0 {
+    return fmt.Errorf("document %s not found", positional[0])
+  }
+
+  cache, err := openDocumentCache()
+
+  if err != nil {
+    return err
+  }
+
+  highlights, err := loadHighlights(ctx, api, cache, positional[0])
+
+  if err != nil {
+    return err
+  }
+
+  output, err := exportHighlights(docs[0], highlights, *format)
+
+  if err != nil {
+    return err
+  }
+
+  fmt.Println(output)
+
+  return nil
+}
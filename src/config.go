@@ -1,16 +1,62 @@
 package main
 
 import (
+  "context"
   "encoding/json"
   "fmt"
   "os"
   "os/exec"
   "path/filepath"
   "runtime"
+  "strings"
+  "time"
 )
 
 type Config struct {
-  Token string `json:"token"`
+  Browser      string              `json:"browser,omitempty"`
+  Columns      []string            `json:"columns,omitempty"`
+  GlamourStyle string              `json:"glamour_style,omitempty"`
+  ImageViewer  string              `json:"image_viewer,omitempty"`
+  Keys         map[string][]string `json:"keys,omitempty"`
+  Margin       *int                `json:"margin,omitempty"`
+  MaxWidth     int                 `json:"max_width,omitempty"`
+  Sort         string              `json:"sort,omitempty"`
+  SortOrder    string              `json:"sort_order,omitempty"`
+  Theme        string              `json:"theme,omitempty"`
+  Token        string              `json:"token"`
+}
+
+const (
+  defaultMargin   = 2
+  defaultMaxWidth = 100
+)
+
+// The document is wrapped to the terminal width, less the margin on either
+// side, but never wider than the configured maximum line width.
+func (c *Config) wrapWidth(terminalWidth int) int {
+  margin := c.margin()
+
+  maxWidth := c.MaxWidth
+
+  if maxWidth <= 0 {
+    maxWidth = defaultMaxWidth
+  }
+
+  return max(20, min(terminalWidth-2*margin, maxWidth))
+}
+
+func (c *Config) margin() int {
+  if c.Margin == nil {
+    return defaultMargin
+  }
+
+  return max(0, *c.Margin)
+}
+
+type SyncState struct {
+  LastFullSync    time.Time          `json:"last_full_sync"`
+  LastSync        time.Time          `json:"last_sync"`
+  PendingProgress map[string]float64 `json:"pending_progress,omitempty"`
 }
 
 func getConfigDir() (string, error) {
@@ -78,20 +124,111 @@ func saveConfig(config *Config) error {
     return fmt.Errorf("failed to marshal config: %w", err)
   }
 
-  if err := os.WriteFile(configPath, data, 0600); err != nil {
+  if err := writeFileAtomic(configPath, data); err != nil {
     return fmt.Errorf("failed to write config file: %w", err)
   }
 
   return nil
 }
 
-func setToken(token string) error {
+// The config holds the token, so it is written to a temporary file and
+// renamed into place. Readers never see it half written, and writers running
+// at the same time can't interleave their contents.
+func writeFileAtomic(path string, data []byte) error {
+  file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+
+  if err != nil {
+    return err
+  }
+
+  _, err = file.Write(data)
+
+  if closeErr := file.Close(); err == nil {
+    err = closeErr
+  }
+
+  if err == nil {
+    err = os.Rename(file.Name(), path)
+  }
+
+  if err != nil {
+    // The write already failed, so a leftover temporary file is the lesser
+    // problem and not reported.
+    _ = os.Remove(file.Name())
+  }
+
+  return err
+}
+
+func getSyncStatePath() (string, error) {
+  configDir, err := getConfigDir()
+
+  if err != nil {
+    return "", err
+  }
+
+  return filepath.Join(configDir, "sync.json"), nil
+}
+
+func loadSyncState() (*SyncState, error) {
+  statePath, err := getSyncStatePath()
+
+  if err != nil {
+    return nil, err
+  }
+
+  if _, err := os.Stat(statePath); os.IsNotExist(err) {
+    return &SyncState{}, nil
+  }
+
+  data, err := os.ReadFile(statePath)
+
+  if err != nil {
+    return nil, fmt.Errorf("failed to read sync state: %w", err)
+  }
+
+  var state SyncState
+
+  if err := json.Unmarshal(data, &state); err != nil {
+    return nil, fmt.Errorf("failed to parse sync state: %w", err)
+  }
+
+  return &state, nil
+}
+
+func saveSyncState(state *SyncState) error {
+  statePath, err := getSyncStatePath()
+
+  if err != nil {
+    return err
+  }
+
+  data, err := json.MarshalIndent(state, "", "  ")
+
+  if err != nil {
+    return fmt.Errorf("failed to marshal sync state: %w", err)
+  }
+
+  if err := writeFileAtomic(statePath, data); err != nil {
+    return fmt.Errorf("failed to write sync state: %w", err)
+  }
+
+  return nil
+}
+
+func setToken(ctx context.Context, token string) error {
   config, err := loadConfig()
 
   if err != nil {
     return err
   }
 
+  token = strings.TrimSpace(token)
+
+  if err := NewReaderAPI(token).ValidateToken(ctx); err != nil {
+    return err
+  }
+
   config.Token = token
 
   if err := saveConfig(config); err != nil {
@@ -101,38 +238,95 @@ func setToken(token string) error {
   return nil
 }
 
-func getToken() (string, error) {
+func getTokenSource() (string, string, error) {
   if token := os.Getenv("READWISE_TOKEN"); token != "" {
-    return token, nil
+    return token, "READWISE_TOKEN environment variable", nil
   }
 
   config, err := loadConfig()
 
   if err != nil {
-    return "", err
+    return "", "", err
   }
 
   if config.Token == "" {
-    return "", fmt.Errorf("no token found. Set it with `reader config set-token <token>`\nGet your token from https://readwise.io/access_token")
+    return "", "", fmt.Errorf("no token found. Set it with `reader config set-token <token>`\nGet your token from https://readwise.io/access_token")
   }
 
-  return config.Token, nil
+  configPath, err := getConfigPath()
+
+  if err != nil {
+    return "", "", err
+  }
+
+  return config.Token, "config file " + configPath, nil
 }
 
-func openTokenURL() error {
-  url := "https://readwise.io/access_token"
+func getToken() (string, error) {
+  token, _, err := getTokenSource()
 
-  var cmd *exec.Cmd
+  return token, err
+}
 
+func systemOpenCommand(target string) (*exec.Cmd, error) {
   switch runtime.GOOS {
   case "darwin":
-    cmd = exec.Command("open", url)
+    return exec.Command("open", target), nil
   case "windows":
-    cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+    return exec.Command("rundll32", "url.dll,FileProtocolHandler", target), nil
   case "linux":
-    cmd = exec.Command("xdg-open", url)
+    return exec.Command("xdg-open", target), nil
   default:
-    return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+    return nil, fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+  }
+}
+
+// A configured command receives the target in place of a `{url}` argument, or
+// as its last argument when there is none.
+func openCommand(command, target string) (*exec.Cmd, error) {
+  fields := strings.Fields(command)
+
+  if len(fields) == 0 {
+    return systemOpenCommand(target)
+  }
+
+  replaced := false
+
+  for i, field := range fields {
+    if strings.Contains(field, "{url}") {
+      fields[i] = strings.ReplaceAll(field, "{url}", target)
+      replaced = true
+    }
+  }
+
+  if !replaced {
+    fields = append(fields, target)
+  }
+
+  return exec.Command(fields[0], fields[1:]...), nil
+}
+
+// READER_BROWSER takes precedence over the config file so a different opener
+// can be used for a single session, such as over SSH.
+func (c *Config) browserCommand() string {
+  if command := os.Getenv("READER_BROWSER"); command != "" {
+    return command
+  }
+
+  return c.Browser
+}
+
+func openURL(target string) error {
+  config, err := loadConfig()
+
+  if err != nil {
+    return err
+  }
+
+  cmd, err := openCommand(config.browserCommand(), target)
+
+  if err != nil {
+    return err
   }
 
   if err := cmd.Run(); err != nil {
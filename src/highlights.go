@@ -0,0 +1,430 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  tea "github.com/charmbracelet/bubbletea"
+  "github.com/charmbracelet/x/ansi"
+  "html"
+  "regexp"
+  "strings"
+  "time"
+  "unicode"
+)
+
+type lineWord struct {
+  line  int
+  start int
+  end   int
+  text  string
+}
+
+func isHighlight(doc Document) bool {
+  return doc.Category == "highlight" && doc.ParentID != ""
+}
+
+func highlightsFor(documents []Document, documentID string) []Document {
+  var highlights []Document
+
+  for _, doc := range documents {
+    if isHighlight(doc) && doc.ParentID == documentID {
+      highlights = append(highlights, doc)
+    }
+  }
+
+  return highlights
+}
+
+// The cached highlights are brought up to date with only those changed since
+// the last sync, rather than listing every highlight in the library to find
+// the few that belong to one document.
+func loadHighlights(ctx context.Context, api *ReaderAPI, cache *DocumentCache, documentID string) ([]Document, error) {
+  cached, err := cache.Load()
+
+  if err != nil {
+    return nil, err
+  }
+
+  state, err := loadSyncState()
+
+  if err != nil {
+    return nil, err
+  }
+
+  since := state.LastSync
+
+  if len(cached) == 0 {
+    since = time.Time{}
+  }
+
+  updates, err := api.GetHighlights(ctx, since)
+
+  if err != nil {
+    return nil, err
+  }
+
+  return highlightsFor(mergeDocuments(cached, updates), documentID), nil
+}
+
+func groupHighlights(documents []Document) map[string][]Document {
+  grouped := make(map[string][]Document)
+
+  for _, doc := range documents {
+    if isHighlight(doc) {
+      grouped[doc.ParentID] = append(grouped[doc.ParentID], doc)
+    }
+  }
+
+  return grouped
+}
+
+func normalizeWord(word string) string {
+  word = linkMarkerPattern.ReplaceAllString(html.UnescapeString(word), "")
+
+  return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+  }))
+}
+
+func splitLineWords(lines []string) []lineWord {
+  var words []lineWord
+
+  for i, line := range lines {
+    for _, word := range wordsIn(ansi.Strip(line)) {
+      word.line = i
+      words = append(words, word)
+    }
+  }
+
+  return words
+}
+
+// Highlighted passages are located by their words rather than their raw text,
+// since glamour wraps, indents and decorates the rendered markdown. Lines that
+// contain a highlight lose their other styling so the highlight stays legible.
+func applyHighlights(lines []string, highlights []Document) ([]string, map[string]int) {
+  positions := make(map[string]int)
+
+  if len(highlights) == 0 {
+    return lines, positions
+  }
+
+  words := splitLineWords(lines)
+
+  type span struct{ start, end int }
+
+  spans := make(map[int][]span)
+
+  for _, highlight := range highlights {
+    var needle []string
+
+    for _, word := range strings.Fields(highlight.Content) {
+      if text := normalizeWord(word); text != "" {
+        needle = append(needle, text)
+      }
+    }
+
+    if len(needle) == 0 {
+      continue
+    }
+
+    for i := 0; i+len(needle) <= len(words); i++ {
+      matched := true
+
+      for j, text := range needle {
+        if words[i+j].text != text {
+          matched = false
+          break
+        }
+      }
+
+      if !matched {
+        continue
+      }
+
+      positions[highlight.ID] = words[i].line
+
+      first, last := words[i], words[i+len(needle)-1]
+
+      for line := first.line; line <= last.line; line++ {
+        plain := ansi.Strip(lines[line])
+
+        start, end := 0, len(plain)
+
+        if line == first.line {
+          start = first.start
+        }
+
+        if line == last.line {
+          end = last.end
+        }
+
+        spans[line] = append(spans[line], span{start, end})
+      }
+
+      break
+    }
+  }
+
+  result := make([]string, len(lines))
+
+  copy(result, lines)
+
+  for line, lineSpans := range spans {
+    plain := ansi.Strip(lines[line])
+
+    marked := make([]bool, len(plain))
+
+    for _, s := range lineSpans {
+      for i := s.start; i < s.end; i++ {
+        marked[i] = true
+      }
+    }
+
+    var b strings.Builder
+
+    for start := 0; start < len(plain); {
+      end := start
+
+      for end < len(plain) && marked[end] == marked[start] {
+        end++
+      }
+
+      if marked[start] {
+        b.WriteString(styles.Highlight.Render(plain[start:end]))
+      } else {
+        b.WriteString(plain[start:end])
+      }
+
+      start = end
+    }
+
+    result[line] = b.String()
+  }
+
+  return result, positions
+}
+
+func exportHighlights(doc Document, highlights []Document, format string) (string, error) {
+  switch format {
+  case "json":
+    data, err := json.MarshalIndent(highlights, "", "  ")
+
+    if err != nil {
+      return "", fmt.Errorf("failed to marshal highlights: %w", err)
+    }
+
+    return string(data), nil
+  case "markdown", "md":
+    var b strings.Builder
+
+    title := doc.Title
+
+    if title == "" {
+      title = doc.ID
+    }
+
+    fmt.Fprintf(&b, "# %s\n", title)
+
+    if doc.Author != "" {
+      fmt.Fprintf(&b, "\n*by %s*\n", doc.Author)
+    }
+
+    if doc.SourceURL != "" {
+      fmt.Fprintf(&b, "\n<%s>\n", doc.SourceURL)
+    }
+
+    for _, highlight := range highlights {
+      b.WriteString("\n")
+
+      for _, line := range strings.Split(strings.TrimSpace(highlight.Content), "\n") {
+        fmt.Fprintf(&b, "> %s\n", line)
+      }
+
+      if note := strings.TrimSpace(highlight.Notes); note != "" {
+        fmt.Fprintf(&b, "\nNote: %s\n", note)
+      }
+    }
+
+    return b.String(), nil
+  default:
+    return "", fmt.Errorf("unknown export format '%s': expected markdown or json", format)
+  }
+}
+
+// The highlight is saved to Readwise even when caching the local copy fails,
+// so that is reported alongside it rather than as a failure.
+type highlightCreatedMsg struct {
+  highlight Document
+  cacheErr  error
+}
+
+type highlightCreateFailedMsg struct {
+  err error
+}
+
+var (
+  markdownImagePattern     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+  markdownLinkPattern      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+  markdownEmphasisPattern  = regexp.MustCompile("(\\*\\*|__|\\*|_|~~|`)")
+  markdownParagraphPattern = regexp.MustCompile(`\n\s*\n`)
+  markdownPrefixPattern    = regexp.MustCompile(`(?m)^\s*(#{1,6}\s+|>\s?|[-*+]\s+|\d+\.\s+)`)
+)
+
+func wordsIn(text string) []lineWord {
+  var words []lineWord
+
+  start := -1
+
+  for i, r := range text + " " {
+    if unicode.IsSpace(r) {
+      if start >= 0 {
+        if word := normalizeWord(text[start:i]); word != "" {
+          words = append(words, lineWord{start: start, end: i, text: word})
+        }
+        start = -1
+      }
+    } else if start < 0 {
+      start = i
+    }
+  }
+
+  return words
+}
+
+func markdownToText(markdown string) string {
+  text := strings.ReplaceAll(markdown, "\\\n", "\n")
+  text = markdownImagePattern.ReplaceAllString(text, "$1")
+  text = markdownLinkPattern.ReplaceAllString(text, "$1")
+  text = linkMarkerPattern.ReplaceAllString(text, "")
+  text = markdownPrefixPattern.ReplaceAllString(text, "")
+  text = markdownEmphasisPattern.ReplaceAllString(text, "")
+
+  var paragraphs []string
+
+  for _, paragraph := range markdownParagraphPattern.Split(text, -1) {
+    if paragraph = strings.Join(strings.Fields(paragraph), " "); paragraph != "" {
+      paragraphs = append(paragraphs, paragraph)
+    }
+  }
+
+  return html.UnescapeString(strings.Join(paragraphs, "\n\n"))
+}
+
+func findWords(haystack, needle []lineWord, from int) int {
+  for i := from; i+len(needle) <= len(haystack); i++ {
+    matched := true
+
+    for j := range needle {
+      if haystack[i+j].text != needle[j].text {
+        matched = false
+        break
+      }
+    }
+
+    if matched {
+      return i
+    }
+  }
+
+  return -1
+}
+
+// The rendered lines carry glamour's styling and wrapping, so the selection is
+// mapped back onto the markdown source by anchoring on its first and last few
+// words. When that fails the visible text of the selection is used as is.
+func selectionText(markdown string, lines []string, start, end int) string {
+  var selected []lineWord
+
+  var plain []string
+
+  for _, line := range lines[start : end+1] {
+    text := strings.TrimSpace(ansi.Strip(line))
+
+    plain = append(plain, text)
+    selected = append(selected, wordsIn(text)...)
+  }
+
+  fallback := markdownToText(strings.Join(plain, "\n"))
+
+  if len(selected) == 0 {
+    return fallback
+  }
+
+  source := wordsIn(markdown)
+
+  anchor := min(3, len(selected))
+
+  first := findWords(source, selected[:anchor], 0)
+
+  if first < 0 {
+    return fallback
+  }
+
+  last := findWords(source, selected[len(selected)-anchor:], first)
+
+  if last < 0 {
+    return fallback
+  }
+
+  return markdownToText(markdown[source[first].start:source[last+anchor-1].end])
+}
+
+// Highlights are created through the Readwise API, which files them under a
+// Readwise book for the document's URL rather than attaching them to the
+// Reader document. Reader never returns them, so a local copy is cached under
+// an ID of its own to keep showing them inline.
+func createHighlight(ctx context.Context, api *ReaderAPI, cache *DocumentCache, doc Document, text, note string) tea.Cmd {
+  return func() tea.Msg {
+    sourceURL := doc.SourceURL
+
+    if sourceURL == "" {
+      sourceURL = doc.URL
+    }
+
+    err := api.CreateHighlight(ctx, HighlightRequest{
+      Text:          text,
+      Title:         doc.Title,
+      Author:        doc.Author,
+      SourceURL:     sourceURL,
+      SourceType:    "reader-tui",
+      Category:      "articles",
+      Note:          note,
+      HighlightedAt: time.Now().UTC().Format(time.RFC3339),
+    })
+
+    if err != nil {
+      return highlightCreateFailedMsg{err: err}
+    }
+
+    highlight := Document{
+      ID:        fmt.Sprintf("%s%d", localHighlightPrefix, time.Now().UnixNano()),
+      Category:  "highlight",
+      Content:   text,
+      CreatedAt: Timestamp{time.Now()},
+      Notes:     note,
+      ParentID:  doc.ID,
+    }
+
+    return highlightCreatedMsg{highlight: highlight, cacheErr: cache.Put(highlight)}
+  }
+}
+
+const localHighlightPrefix = "local-"
+
+func isLocalHighlight(doc Document) bool {
+  return isHighlight(doc) && strings.HasPrefix(doc.ID, localHighlightPrefix)
+}
+
+func localHighlights(documents []Document) []Document {
+  var local []Document
+
+  for _, doc := range documents {
+    if isLocalHighlight(doc) {
+      local = append(local, doc)
+    }
+  }
+
+  return local
+}
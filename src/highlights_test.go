@@ -0,0 +1,127 @@
+package main
+
+import (
+  "context"
+  "github.com/charmbracelet/x/ansi"
+  "net/http"
+  "path/filepath"
+  "slices"
+  "strings"
+  "testing"
+)
+
+func TestHighlightsFor(t *testing.T) {
+  documents := []Document{
+    {ID: "h1", Category: "highlight", ParentID: "doc"},
+    {ID: "h2", Category: "highlight", ParentID: "other"},
+    {ID: "h3", Category: "highlight", ParentID: "doc"},
+    {ID: "doc", Category: "article"},
+    {ID: "orphan", Category: "highlight"},
+  }
+
+  if ids := documentIDs(highlightsFor(documents, "doc")); !slices.Equal(ids, []string{"h1", "h3"}) {
+    t.Errorf("highlightsFor = %v, want [h1 h3]", ids)
+  }
+}
+
+func TestApplyHighlights(t *testing.T) {
+  lines := []string{
+    "  The quick brown fox",
+    "  jumps over the lazy",
+    "  dog. **Bold** text here.",
+  }
+
+  tests := []struct {
+    name     string
+    content  string
+    position int
+    found    bool
+  }{
+    {name: "single line", content: "quick brown", position: 0, found: true},
+    {name: "across lines", content: "brown fox jumps over", position: 0, found: true},
+    {name: "ignores punctuation and case", content: "Lazy dog", position: 1, found: true},
+    {name: "ignores markdown styling", content: "bold text", position: 2, found: true},
+    {name: "missing", content: "slow turtle", found: false},
+    {name: "empty", content: "...", found: false},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      highlights := []Document{{ID: "h", Content: test.content}}
+
+      result, positions := applyHighlights(lines, highlights)
+
+      position, found := positions["h"]
+
+      if found != test.found || (found && position != test.position) {
+        t.Errorf("position = %d, %v, want %d, %v", position, found, test.position, test.found)
+      }
+
+      for i, line := range result {
+        if ansi.Strip(line) != lines[i] {
+          t.Errorf("line %d changed its text: %q", i, ansi.Strip(line))
+        }
+      }
+    })
+  }
+}
+
+func TestSelectionText(t *testing.T) {
+  markdown := "# Title\n\nSome **bold** text and a [link](https://example.com) here.\n\nA second paragraph\nthat wraps.\n"
+
+  lines := []string{
+    "  \x1b[1mTitle\x1b[0m",
+    "",
+    "  Some \x1b[1mbold\x1b[0m text and a link here.",
+    "",
+    "  A second paragraph that",
+    "  wraps.",
+  }
+
+  tests := []struct {
+    name       string
+    start, end int
+    want       string
+  }{
+    {name: "one line", start: 2, end: 2, want: "Some bold text and a link here."},
+    {name: "wrapped lines", start: 4, end: 5, want: "A second paragraph that wraps."},
+    {name: "across paragraphs", start: 2, end: 5, want: "Some bold text and a link here.\n\nA second paragraph that wraps."},
+    {name: "blank line", start: 1, end: 1, want: ""},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      if got := selectionText(markdown, lines, test.start, test.end); got != test.want {
+        t.Errorf("selectionText = %q, want %q", got, test.want)
+      }
+    })
+  }
+}
+
+func TestCreateHighlightKeepsHighlightWhenCacheFails(t *testing.T) {
+  api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusCreated)
+  })
+
+  cache := &DocumentCache{dir: filepath.Join(t.TempDir(), "missing")}
+
+  msg := createHighlight(context.Background(), api, cache, Document{ID: "doc", URL: "https://example.com"}, "text", "")()
+
+  created, ok := msg.(highlightCreatedMsg)
+
+  if !ok || created.cacheErr == nil {
+    t.Fatalf("expected the highlight with a cache error, got %#v", msg)
+  }
+
+  model, _ := App{}.Update(created)
+
+  m := model.(App)
+
+  if len(m.highlights["doc"]) != 1 {
+    t.Errorf("expected the highlight to be shown, got %v", m.highlights)
+  }
+
+  if !strings.Contains(m.status, "Highlight saved to Readwise, but failed to update cache") {
+    t.Errorf("expected the cache error in the status, got %q", m.status)
+  }
+}
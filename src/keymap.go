@@ -0,0 +1,344 @@
+package main
+
+import (
+  "fmt"
+  "github.com/charmbracelet/bubbles/key"
+  tea "github.com/charmbracelet/bubbletea"
+  "sort"
+  "strings"
+)
+
+type KeyMap struct {
+  Up            key.Binding
+  Down          key.Binding
+  PageUp        key.Binding
+  PageDown      key.Binding
+  PrevCategory  key.Binding
+  NextCategory  key.Binding
+  PrevType      key.Binding
+  NextType      key.Binding
+  Select        key.Binding
+  Back          key.Binding
+  Cancel        key.Binding
+  Confirm       key.Binding
+  Quit          key.Binding
+  Search        key.Binding
+  Tags          key.Binding
+  EditTags      key.Binding
+  Delete        key.Binding
+  Refresh       key.Binding
+  FullRefresh   key.Binding
+  RefreshType   key.Binding
+  MoveNew       key.Binding
+  MoveLater     key.Binding
+  MoveShortlist key.Binding
+  MoveArchive   key.Binding
+  MoveFeed      key.Binding
+  OpenURL       key.Binding
+  CopyURL       key.Binding
+  Visual        key.Binding
+  Images        key.Binding
+  Highlights    key.Binding
+  Links         key.Binding
+  NextLink      key.Binding
+  PrevLink      key.Binding
+  SaveLink      key.Binding
+  Sort          key.Binding
+  SortOrder     key.Binding
+}
+
+type moveBinding struct {
+  binding  key.Binding
+  location string
+}
+
+var keyNames = map[string]string{
+  "up":    "↑",
+  "down":  "↓",
+  "left":  "←",
+  "right": "→",
+}
+
+func defaultKeyMap() KeyMap {
+  return KeyMap{
+    Up:            key.NewBinding(key.WithKeys("up", "k")),
+    Down:          key.NewBinding(key.WithKeys("down", "j")),
+    PageUp:        key.NewBinding(key.WithKeys("ctrl+u")),
+    PageDown:      key.NewBinding(key.WithKeys("ctrl+d")),
+    PrevCategory:  key.NewBinding(key.WithKeys("left", "h")),
+    NextCategory:  key.NewBinding(key.WithKeys("right", "l")),
+    PrevType:      key.NewBinding(key.WithKeys("[")),
+    NextType:      key.NewBinding(key.WithKeys("]")),
+    Select:        key.NewBinding(key.WithKeys("enter")),
+    Back:          key.NewBinding(key.WithKeys("esc", "backspace")),
+    Cancel:        key.NewBinding(key.WithKeys("esc")),
+    Confirm:       key.NewBinding(key.WithKeys("y", "Y")),
+    Quit:          key.NewBinding(key.WithKeys("q", "ctrl+c")),
+    Search:        key.NewBinding(key.WithKeys("/")),
+    Tags:          key.NewBinding(key.WithKeys("t")),
+    EditTags:      key.NewBinding(key.WithKeys("T")),
+    Delete:        key.NewBinding(key.WithKeys("d")),
+    Refresh:       key.NewBinding(key.WithKeys("r")),
+    FullRefresh:   key.NewBinding(key.WithKeys("R")),
+    RefreshType:   key.NewBinding(key.WithKeys("ctrl+r")),
+    MoveNew:       key.NewBinding(key.WithKeys("N")),
+    MoveLater:     key.NewBinding(key.WithKeys("L")),
+    MoveShortlist: key.NewBinding(key.WithKeys("S")),
+    MoveArchive:   key.NewBinding(key.WithKeys("A")),
+    MoveFeed:      key.NewBinding(key.WithKeys("F")),
+    OpenURL:       key.NewBinding(key.WithKeys("o")),
+    CopyURL:       key.NewBinding(key.WithKeys("y")),
+    Visual:        key.NewBinding(key.WithKeys("v")),
+    Images:        key.NewBinding(key.WithKeys("i")),
+    Highlights:    key.NewBinding(key.WithKeys("H")),
+    Links:         key.NewBinding(key.WithKeys("f")),
+    NextLink:      key.NewBinding(key.WithKeys("tab")),
+    PrevLink:      key.NewBinding(key.WithKeys("shift+tab")),
+    SaveLink:      key.NewBinding(key.WithKeys("s")),
+    Sort:          key.NewBinding(key.WithKeys("s")),
+    SortOrder:     key.NewBinding(key.WithKeys("O")),
+  }
+}
+
+func (k *KeyMap) actions() map[string]*key.Binding {
+  return map[string]*key.Binding{
+    "up":             &k.Up,
+    "down":           &k.Down,
+    "page_up":        &k.PageUp,
+    "page_down":      &k.PageDown,
+    "prev_category":  &k.PrevCategory,
+    "next_category":  &k.NextCategory,
+    "prev_type":      &k.PrevType,
+    "next_type":      &k.NextType,
+    "select":         &k.Select,
+    "back":           &k.Back,
+    "cancel":         &k.Cancel,
+    "confirm":        &k.Confirm,
+    "quit":           &k.Quit,
+    "search":         &k.Search,
+    "tags":           &k.Tags,
+    "edit_tags":      &k.EditTags,
+    "delete":         &k.Delete,
+    "refresh":        &k.Refresh,
+    "full_refresh":   &k.FullRefresh,
+    "refresh_type":   &k.RefreshType,
+    "move_new":       &k.MoveNew,
+    "move_later":     &k.MoveLater,
+    "move_shortlist": &k.MoveShortlist,
+    "move_archive":   &k.MoveArchive,
+    "move_feed":      &k.MoveFeed,
+    "open_url":       &k.OpenURL,
+    "copy_url":       &k.CopyURL,
+    "visual":         &k.Visual,
+    "images":         &k.Images,
+    "highlights":     &k.Highlights,
+    "links":          &k.Links,
+    "next_link":      &k.NextLink,
+    "prev_link":      &k.PrevLink,
+    "save_link":      &k.SaveLink,
+    "sort":           &k.Sort,
+    "sort_order":     &k.SortOrder,
+  }
+}
+
+// Bindings from the config file replace the defaults for their action
+// entirely, so an action can be moved off a key another action needs.
+func newKeyMap(overrides map[string][]string) (KeyMap, error) {
+  keys := defaultKeyMap()
+
+  actions := keys.actions()
+
+  for action, bound := range overrides {
+    binding, ok := actions[action]
+
+    if !ok {
+      names := make([]string, 0, len(actions))
+
+      for name := range actions {
+        names = append(names, name)
+      }
+
+      sort.Strings(names)
+
+      return KeyMap{}, fmt.Errorf("unknown key action '%s': expected one of %s", action, strings.Join(names, ", "))
+    }
+
+    if len(bound) == 0 {
+      return KeyMap{}, fmt.Errorf("no keys bound to action '%s'", action)
+    }
+
+    binding.SetKeys(bound...)
+  }
+
+  if err := keys.checkConflicts(); err != nil {
+    return KeyMap{}, err
+  }
+
+  return keys, nil
+}
+
+// Actions only conflict when they are available at the same time, so the
+// same key can mean different things in the list and the reading view.
+var keyContexts = []struct {
+  name    string
+  actions []string
+}{
+  {"document list", []string{"up", "down", "page_up", "page_down", "prev_category", "next_category", "prev_type", "next_type", "select", "cancel", "quit", "search", "tags", "edit_tags", "delete", "refresh", "full_refresh", "refresh_type", "move_new", "move_later", "move_shortlist", "move_archive", "move_feed", "open_url", "copy_url", "sort", "sort_order"}},
+  {"reading view", []string{"up", "down", "page_up", "page_down", "select", "back", "quit", "open_url", "copy_url", "visual", "images", "highlights", "links", "next_link", "prev_link", "save_link"}},
+  {"selection", []string{"up", "down", "page_up", "page_down", "visual", "select", "cancel"}},
+  {"tag list", []string{"up", "down", "select", "cancel", "tags", "quit"}},
+  {"image list", []string{"up", "down", "select", "back", "images", "quit"}},
+  {"link list", []string{"up", "down", "select", "save_link", "back", "links", "quit"}},
+  {"highlight list", []string{"up", "down", "select", "back", "highlights", "quit"}},
+}
+
+func (k *KeyMap) checkConflicts() error {
+  actions := k.actions()
+
+  for _, context := range keyContexts {
+    bound := make(map[string]string)
+
+    for _, action := range context.actions {
+      for _, name := range actions[action].Keys() {
+        if other, ok := bound[name]; ok {
+          return fmt.Errorf("key '%s' is bound to both '%s' and '%s' in the %s", name, other, action, context.name)
+        }
+
+        bound[name] = action
+      }
+    }
+  }
+
+  return nil
+}
+
+func (k KeyMap) moveBindings() []moveBinding {
+  return []moveBinding{
+    {k.MoveNew, "new"},
+    {k.MoveLater, "later"},
+    {k.MoveShortlist, "shortlist"},
+    {k.MoveArchive, "archive"},
+    {k.MoveFeed, "feed"},
+  }
+}
+
+func (k KeyMap) moveLocation(msg tea.KeyMsg) (string, bool) {
+  for _, move := range k.moveBindings() {
+    if key.Matches(msg, move.binding) {
+      return move.location, true
+    }
+  }
+
+  return "", false
+}
+
+func (k KeyMap) requiresNetwork(msg tea.KeyMsg) bool {
+  if _, ok := k.moveLocation(msg); ok {
+    return true
+  }
+
+  return key.Matches(msg, k.Delete, k.Refresh, k.FullRefresh, k.RefreshType, k.EditTags)
+}
+
+// Related bindings share one help entry, with their keys listed in parallel,
+// so up and down bound to ["up", "k"] and ["down", "j"] read "↑/↓ k/j". A
+// single binding only shows its first key to keep the help line short.
+func helpEntry(description string, bindings ...key.Binding) string {
+  var columns []string
+
+  for i := 0; len(bindings) > 1 || i == 0; i++ {
+    var column []string
+
+    for _, binding := range bindings {
+      if keys := binding.Keys(); i < len(keys) {
+        name := keys[i]
+
+        if display, ok := keyNames[name]; ok {
+          name = display
+        }
+
+        column = append(column, name)
+      }
+    }
+
+    if len(column) == 0 {
+      break
+    }
+
+    columns = append(columns, strings.Join(column, "/"))
+  }
+
+  return strings.Join(columns, " ") + " " + description
+}
+
+func (k KeyMap) listHelp(categories, types bool) string {
+  entries := []string{
+    helpEntry("move", k.Up, k.Down),
+    helpEntry("read", k.Select),
+    helpEntry("search", k.Search),
+    helpEntry("open", k.OpenURL),
+    helpEntry("copy URL", k.CopyURL),
+    helpEntry("tags", k.Tags),
+    helpEntry("edit tags", k.EditTags),
+    helpEntry("sort/order", k.Sort, k.SortOrder),
+  }
+
+  if categories {
+    entries = append(entries, helpEntry("switch category", k.PrevCategory, k.NextCategory))
+  }
+
+  if types {
+    entries = append(entries, helpEntry("switch type", k.PrevType, k.NextType), helpEntry("refresh type", k.RefreshType))
+  }
+
+  var moves []key.Binding
+
+  var locations []string
+
+  for _, move := range k.moveBindings() {
+    moves = append(moves, move.binding)
+    locations = append(locations, move.location)
+  }
+
+  return strings.Join(append(entries,
+    helpEntry("move to "+strings.Join(locations, "/"), moves...),
+    helpEntry("delete", k.Delete),
+    helpEntry("refresh/full refresh", k.Refresh, k.FullRefresh),
+    helpEntry("quit", k.Quit),
+  ), ", ")
+}
+
+func (k KeyMap) readHelp() string {
+  return strings.Join([]string{
+    helpEntry("scroll", k.Up, k.Down),
+    helpEntry("links", k.NextLink),
+    helpEntry("link list", k.Links),
+    helpEntry("open", k.OpenURL),
+    helpEntry("copy URL", k.CopyURL),
+    helpEntry("select", k.Visual),
+    helpEntry("highlights", k.Highlights),
+    helpEntry("images", k.Images),
+    helpEntry("back", k.Back),
+    helpEntry("quit", k.Quit),
+  }, ", ")
+}
+
+func (k KeyMap) visualHelp() string {
+  return strings.Join([]string{
+    helpEntry("extend", k.Up, k.Down),
+    helpEntry("save highlight to Readwise", k.Visual, k.Select),
+    helpEntry("cancel", k.Cancel),
+  }, ", ")
+}
+
+func (k KeyMap) pickerHelp(action string, extra ...string) string {
+  entries := []string{
+    helpEntry("move", k.Up, k.Down),
+    helpEntry(action, k.Select),
+  }
+
+  return strings.Join(append(append(entries, extra...),
+    helpEntry("back", k.Back),
+    helpEntry("quit", k.Quit),
+  ), ", ")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+  "slices"
+  "strings"
+  "testing"
+)
+
+func TestNewKeyMap(t *testing.T) {
+  tests := []struct {
+    name      string
+    overrides map[string][]string
+    action    string
+    keys      []string
+    err       string
+  }{
+    {
+      name:   "defaults",
+      action: "confirm",
+      keys:   []string{"y", "Y"},
+    },
+    {
+      name:      "replaces the default keys",
+      overrides: map[string][]string{"delete": {"x"}},
+      action:    "delete",
+      keys:      []string{"x"},
+    },
+    {
+      name:      "frees a key for another action",
+      overrides: map[string][]string{"sort": {"z"}, "delete": {"s"}},
+      action:    "delete",
+      keys:      []string{"s"},
+    },
+    {
+      name:      "allows the same key in different views",
+      overrides: map[string][]string{"delete": {"v"}},
+      action:    "delete",
+      keys:      []string{"v"},
+    },
+    {
+      name:      "unknown action",
+      overrides: map[string][]string{"explode": {"x"}},
+      err:       "unknown key action 'explode'",
+    },
+    {
+      name:      "no keys",
+      overrides: map[string][]string{"delete": {}},
+      err:       "no keys bound to action 'delete'",
+    },
+    {
+      name:      "conflict in the document list",
+      overrides: map[string][]string{"delete": {"r"}},
+      err:       "key 'r' is bound to both",
+    },
+    {
+      name:      "conflict in the reading view",
+      overrides: map[string][]string{"links": {"v"}},
+      err:       "key 'v' is bound to both",
+    },
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      keys, err := newKeyMap(test.overrides)
+
+      if test.err != "" {
+        if err == nil || !strings.Contains(err.Error(), test.err) {
+          t.Fatalf("expected error containing %q, got %v", test.err, err)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if got := keys.actions()[test.action].Keys(); !slices.Equal(got, test.keys) {
+        t.Errorf("expected %q bound to %v, got %v", test.action, test.keys, got)
+      }
+    })
+  }
+}
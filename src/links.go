@@ -0,0 +1,74 @@
+package main
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  tea "github.com/charmbracelet/bubbletea"
+  "github.com/charmbracelet/x/ansi"
+  "regexp"
+  "strings"
+)
+
+type linkSavedMsg struct {
+  url     string
+  existed bool
+}
+
+type linkSaveFailedMsg struct {
+  url string
+  err error
+}
+
+// Link markers use white square brackets so they can't be mistaken for the
+// citations and footnote references articles write as "[1]".
+var linkMarkerPattern = regexp.MustCompile(`⟦\d+⟧`)
+
+func linkMarker(number int) string {
+  return fmt.Sprintf("⟦%d⟧", number)
+}
+
+func findLinkLine(lines []string, number int) int {
+  marker := linkMarker(number)
+
+  for i, line := range lines {
+    if strings.Contains(ansi.Strip(line), marker) {
+      return i
+    }
+  }
+
+  return -1
+}
+
+// The marker is styled on the plain text of the line, the same trade-off
+// applyHighlights makes, so that glamour's escape codes cannot split it.
+func markLink(line string, number int) string {
+  marker := linkMarker(number)
+
+  plain := ansi.Strip(line)
+
+  if !strings.Contains(plain, marker) {
+    return line
+  }
+
+  return strings.Replace(plain, marker, styles.Mark.Render(marker), 1)
+}
+
+func saveLink(ctx context.Context, api *ReaderAPI, link ContentLink) tea.Cmd {
+  return func() tea.Msg {
+    _, err := api.SaveDocument(ctx, SaveDocumentRequest{
+      URL:        link.URL,
+      SavedUsing: "reader-tui",
+    })
+
+    if errors.Is(err, errDocumentExists) {
+      return linkSavedMsg{url: link.URL, existed: true}
+    }
+
+    if err != nil {
+      return linkSaveFailedMsg{url: link.URL, err: err}
+    }
+
+    return linkSavedMsg{url: link.URL}
+  }
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+  "github.com/charmbracelet/x/ansi"
+  "testing"
+)
+
+func TestFindLinkLine(t *testing.T) {
+  lines := []string{
+    "  As shown in [1], citations look like links.",
+    "  See \x1b[1mthe docs⟦1⟧\x1b[0m and the spec⟦2⟧.",
+    "  Footnote [2] again.",
+  }
+
+  tests := []struct {
+    number int
+    want   int
+  }{
+    {1, 1},
+    {2, 1},
+    {3, -1},
+  }
+
+  for _, test := range tests {
+    if got := findLinkLine(lines, test.number); got != test.want {
+      t.Errorf("findLinkLine(%d) = %d, want %d", test.number, got, test.want)
+    }
+  }
+}
+
+func TestMarkLinkKeepsText(t *testing.T) {
+  line := "  See \x1b[1mthe docs⟦1⟧\x1b[0m and [1]."
+
+  if got := ansi.Strip(markLink(line, 1)); got != "  See the docs⟦1⟧ and [1]." {
+    t.Errorf("markLink changed the text to %q", got)
+  }
+
+  if got := markLink(line, 2); got != line {
+    t.Errorf("markLink changed a line without the marker: %q", got)
+  }
+}
+
+func TestMarkdownToTextKeepsCitations(t *testing.T) {
+  markdown := "Prior work [1] found this⟦3⟧ in *two* studies [2]."
+
+  if got, want := markdownToText(markdown), "Prior work [1] found this in two studies [2]."; got != want {
+    t.Errorf("markdownToText = %q, want %q", got, want)
+  }
+
+  if got := normalizeWord("[1]"); got != "1" {
+    t.Errorf("normalizeWord stripped a citation: %q", got)
+  }
+
+  if got := normalizeWord("this⟦3⟧"); got != "this" {
+    t.Errorf("normalizeWord kept a link marker: %q", got)
+  }
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+  "context"
   "fmt"
   tea "github.com/charmbracelet/bubbletea"
   "log"
   "os"
+  "os/signal"
 )
 
 func help() {
@@ -14,12 +16,23 @@ func help() {
   fmt.Println()
   fmt.Println("Usage:")
   fmt.Println("  reader                          Start the interface")
+  fmt.Println("  reader --offline                Start the interface from the local cache only")
   fmt.Println("  reader config get-token         Open your browser to get your Readwise access token")
   fmt.Println("  reader config set-token <token> Set your Readwise access token")
+  fmt.Println("  reader config check             Check that your access token is valid")
+  fmt.Println("  reader save <url> [options]     Save a URL to Reader")
+  fmt.Println("  reader delete <id>...           Delete documents by ID")
+  fmt.Println("  reader highlights <id>          Export a document's highlights (--format markdown|json)")
+  fmt.Println()
+  fmt.Println("Save options:")
+  fmt.Println("  --title <title>                 Override the document title")
+  fmt.Println("  --author <author>               Override the document author")
+  fmt.Println("  --tags <tag,tag>                Comma-separated tags to apply")
+  fmt.Println("  --location <location>           One of new, later, archive, feed")
 }
 
-func run() {
-  p := tea.NewProgram(NewModel(), tea.WithAltScreen())
+func run(offline bool) {
+  p := tea.NewProgram(NewModel(offline), tea.WithAltScreen())
 
   if _, err := p.Run(); err != nil {
     log.Fatal(err)
@@ -31,11 +44,17 @@ func main() {
   args := os.Args[1:]
 
   if len(args) == 0 {
-    run()
+    run(false)
     return
   }
 
+  ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+  defer stop()
+
   switch args[0] {
+  case "--offline":
+    run(true)
   case "config":
     if len(args) < 2 {
       fmt.Fprintf(os.Stderr, "error: config command requires a subcommand\n\n")
@@ -49,12 +68,17 @@ func main() {
         help()
         os.Exit(1)
       }
-      if err := setToken(args[2]); err != nil {
+      if err := setToken(ctx, args[2]); err != nil {
         fmt.Fprintf(os.Stderr, "error setting token: %s\n", err.Error())
         os.Exit(1)
       }
+    case "check":
+      if err := checkTokenCommand(ctx); err != nil {
+        fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+        os.Exit(1)
+      }
     case "get-token":
-      if err := openTokenURL(); err != nil {
+      if err := openURL("https://readwise.io/access_token"); err != nil {
         fmt.Fprintf(os.Stderr, "error opening token URL: %s\n", err.Error())
         os.Exit(1)
       }
@@ -63,6 +87,21 @@ func main() {
       help()
       os.Exit(1)
     }
+  case "save":
+    if err := saveCommand(ctx, args[1:]); err != nil {
+      fmt.Fprintf(os.Stderr, "error saving document: %s\n", err.Error())
+      os.Exit(1)
+    }
+  case "delete":
+    if err := deleteCommand(ctx, args[1:]); err != nil {
+      fmt.Fprintf(os.Stderr, "error deleting documents: %s\n", err.Error())
+      os.Exit(1)
+    }
+  case "highlights":
+    if err := highlightsCommand(ctx, args[1:]); err != nil {
+      fmt.Fprintf(os.Stderr, "error exporting highlights: %s\n", err.Error())
+      os.Exit(1)
+    }
   case "help", "--help", "-h":
     help()
   default:
@@ -0,0 +1,595 @@
+package main
+
+import (
+  "fmt"
+  "golang.org/x/net/html"
+  "golang.org/x/net/html/atom"
+  "net/url"
+  "regexp"
+  "strconv"
+  "strings"
+)
+
+var (
+  blankLinesPattern  = regexp.MustCompile(`\n{3,}`)
+  blockMarkerPattern = regexp.MustCompile(`^(\s*)([#+=-]|\d+[.)])`)
+  whitespacePattern  = regexp.MustCompile(`\s+`)
+)
+
+var skippedElements = map[atom.Atom]bool{
+  atom.Button:   true,
+  atom.Form:     true,
+  atom.Head:     true,
+  atom.Iframe:   true,
+  atom.Noscript: true,
+  atom.Script:   true,
+  atom.Style:    true,
+  atom.Svg:      true,
+  atom.Template: true,
+}
+
+var blockElements = map[atom.Atom]bool{
+  atom.Blockquote: true,
+  atom.Dl:         true,
+  atom.Figure:     true,
+  atom.Ol:         true,
+  atom.P:          true,
+  atom.Pre:        true,
+  atom.Table:      true,
+  atom.Ul:         true,
+}
+
+// Glamour prints backslash escapes as they are, so markdown syntax in article
+// text is escaped with character references, which it renders as the
+// characters themselves.
+var markdownEscaper = strings.NewReplacer(
+  "&", "&amp;",
+  `\`, "&#92;",
+  "*", "&#42;",
+  "_", "&#95;",
+  "`", "&#96;",
+  "[", "&#91;",
+  "]", "&#93;",
+  "<", "&lt;",
+  ">", "&gt;",
+)
+
+type ContentImage struct {
+  Alt string
+  URL string
+}
+
+type ContentLink struct {
+  Text string
+  URL  string
+}
+
+type ConvertedContent struct {
+  Images   []ContentImage
+  Links    []ContentLink
+  Markdown string
+}
+
+type markdownConverter struct {
+  base   *url.URL
+  images []ContentImage
+  links  []ContentLink
+}
+
+func htmlToMarkdown(input string) string {
+  return convertHTML(input, "").Markdown
+}
+
+func convertHTML(input, baseURL string) ConvertedContent {
+  if input == "" {
+    return ConvertedContent{}
+  }
+
+  root, err := html.Parse(strings.NewReader(input))
+
+  if err != nil {
+    return ConvertedContent{Markdown: strings.TrimSpace(input)}
+  }
+
+  c := &markdownConverter{}
+
+  if base, err := url.Parse(baseURL); err == nil && base.IsAbs() {
+    c.base = base
+  }
+
+  markdown := cleanMarkdown(c.renderChildren(root))
+
+  return ConvertedContent{Images: c.images, Links: c.links, Markdown: markdown}
+}
+
+// Text can start a line, after a block boundary or a line break, where a
+// leading marker would turn it into a heading, list item or setext underline.
+// Escaping the marker wherever text starts is harmless elsewhere, since the
+// reference renders as the same character.
+func escapeMarkdown(text string) string {
+  text = markdownEscaper.Replace(text)
+
+  return blockMarkerPattern.ReplaceAllStringFunc(text, func(marker string) string {
+    last := len(marker) - 1
+
+    return marker[:last] + fmt.Sprintf("&#%d;", marker[last])
+  })
+}
+
+func (c *markdownConverter) resolve(ref string) string {
+  if c.base == nil {
+    return ref
+  }
+
+  parsed, err := url.Parse(ref)
+
+  if err != nil {
+    return ref
+  }
+
+  return c.base.ResolveReference(parsed).String()
+}
+
+// Glamour prints table cells as written, without decoding the character
+// references markdownEscaper uses or dropping the backslash from an escaped
+// pipe. Cells are unescaped instead, with pipes replaced by a lookalike so
+// they don't split the cell, and a zero width space after each < so text
+// isn't taken for an HTML tag and dropped.
+var tableCellEscaper = strings.NewReplacer("|", "∣", "<", "<\u200b")
+
+func tableCell(text string) string {
+  return tableCellEscaper.Replace(html.UnescapeString(text))
+}
+
+func cleanMarkdown(markdown string) string {
+  lines := strings.Split(markdown, "\n")
+
+  for i, line := range lines {
+    lines[i] = strings.TrimRight(line, " \t")
+  }
+
+  markdown = blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+
+  return strings.TrimSpace(markdown)
+}
+
+func (c *markdownConverter) renderChildren(n *html.Node) string {
+  var b strings.Builder
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    b.WriteString(c.renderNode(child))
+  }
+
+  return b.String()
+}
+
+func (c *markdownConverter) renderInline(n *html.Node) string {
+  return strings.TrimSpace(whitespacePattern.ReplaceAllString(c.renderChildren(n), " "))
+}
+
+func (c *markdownConverter) renderNode(n *html.Node) string {
+  switch n.Type {
+  case html.TextNode:
+    return escapeMarkdown(whitespacePattern.ReplaceAllString(n.Data, " "))
+  case html.ElementNode:
+  case html.DocumentNode:
+    return c.renderChildren(n)
+  default:
+    return ""
+  }
+
+  if skippedElements[n.DataAtom] {
+    return ""
+  }
+
+  switch n.DataAtom {
+  case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+    text := c.renderInline(n)
+
+    if text == "" {
+      return ""
+    }
+
+    level := int(n.Data[1] - '0')
+
+    return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+  case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Aside, atom.Nav:
+    return "\n\n" + strings.TrimSpace(c.renderChildren(n)) + "\n\n"
+  case atom.Br:
+    return "\\\n"
+  case atom.Hr:
+    return "\n\n---\n\n"
+  case atom.Strong, atom.B:
+    return wrapInline(c.renderChildren(n), "**")
+  case atom.Em, atom.I, atom.Cite:
+    return wrapInline(c.renderChildren(n), "*")
+  case atom.Del, atom.S, atom.Strike:
+    return wrapInline(c.renderChildren(n), "~~")
+  case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+    return renderInlineCode(textContent(n))
+  case atom.Pre:
+    return c.renderCodeBlock(n)
+  case atom.A:
+    return c.renderLink(n)
+  case atom.Img:
+    return c.renderImage(n)
+  case atom.Ul, atom.Ol:
+    return c.renderList(n)
+  case atom.Blockquote:
+    return "\n\n" + prefixLines(cleanMarkdown(c.renderChildren(n)), "> ", ">") + "\n\n"
+  case atom.Table:
+    return c.renderTable(n)
+  case atom.Figure:
+    return c.renderFigure(n)
+  case atom.Figcaption:
+    return "\n\n" + wrapInline(c.renderInline(n), "*") + "\n\n"
+  case atom.Dl:
+    return c.renderDefinitionList(n)
+  default:
+    return c.renderChildren(n)
+  }
+}
+
+func attr(n *html.Node, key string) string {
+  for _, a := range n.Attr {
+    if a.Key == key {
+      return a.Val
+    }
+  }
+
+  return ""
+}
+
+func textContent(n *html.Node) string {
+  if n.Type == html.TextNode {
+    return n.Data
+  }
+
+  var b strings.Builder
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+      b.WriteString("\n")
+    } else {
+      b.WriteString(textContent(child))
+    }
+  }
+
+  return b.String()
+}
+
+func hasBlockChildren(n *html.Node) bool {
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type == html.ElementNode && blockElements[child.DataAtom] && child.DataAtom != atom.Ul && child.DataAtom != atom.Ol {
+      return true
+    }
+  }
+
+  return false
+}
+
+// Emphasis markers only apply when they hug the text, so surrounding spaces
+// are moved outside of them.
+func wrapInline(text, marker string) string {
+  trimmed := strings.TrimSpace(text)
+
+  if trimmed == "" {
+    return text
+  }
+
+  leading := text[:strings.Index(text, trimmed)]
+  trailing := text[len(leading)+len(trimmed):]
+
+  return leading + marker + trimmed + marker + trailing
+}
+
+func renderInlineCode(code string) string {
+  code = strings.ReplaceAll(code, "\n", " ")
+
+  if strings.TrimSpace(code) == "" {
+    return ""
+  }
+
+  fence := "`"
+
+  for strings.Contains(code, fence) {
+    fence += "`"
+  }
+
+  if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+    code = " " + code + " "
+  }
+
+  return fence + code + fence
+}
+
+func codeLanguage(n *html.Node) string {
+  for _, class := range strings.Fields(attr(n, "class")) {
+    for _, prefix := range []string{"language-", "lang-", "highlight-source-"} {
+      if strings.HasPrefix(class, prefix) {
+        return strings.TrimPrefix(class, prefix)
+      }
+    }
+  }
+
+  if lang := attr(n, "data-lang"); lang != "" {
+    return lang
+  }
+
+  return attr(n, "data-language")
+}
+
+func (c *markdownConverter) renderCodeBlock(n *html.Node) string {
+  language := codeLanguage(n)
+
+  for child := n.FirstChild; child != nil && language == ""; child = child.NextSibling {
+    if child.Type == html.ElementNode && child.DataAtom == atom.Code {
+      language = codeLanguage(child)
+    }
+  }
+
+  code := strings.Trim(textContent(n), "\n")
+
+  fence := "```"
+
+  for strings.Contains(code, fence) {
+    fence += "`"
+  }
+
+  return "\n\n" + fence + language + "\n" + code + "\n" + fence + "\n\n"
+}
+
+func (c *markdownConverter) renderLink(n *html.Node) string {
+  text := c.renderChildren(n)
+  href := strings.TrimSpace(attr(n, "href"))
+
+  if strings.TrimSpace(text) == "" {
+    return text
+  }
+
+  if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+    return text
+  }
+
+  // Block content inside a link, such as a card wrapping a heading, cannot be
+  // expressed as a markdown link, so only its text is kept.
+  if strings.Contains(strings.TrimSpace(text), "\n") {
+    return text
+  }
+
+  href = c.resolve(href)
+
+  number := 0
+
+  for i, link := range c.links {
+    if link.URL == href {
+      number = i + 1
+      break
+    }
+  }
+
+  if number == 0 {
+    c.links = append(c.links, ContentLink{Text: strings.TrimSpace(whitespacePattern.ReplaceAllString(textContent(n), " ")), URL: href})
+    number = len(c.links)
+  }
+
+  trimmed := strings.TrimSpace(text)
+  leading := text[:strings.Index(text, trimmed)]
+  trailing := text[len(leading)+len(trimmed):]
+
+  return leading + trimmed + linkMarker(number) + trailing
+}
+
+// Terminals cannot show images inline, so each one becomes a numbered
+// placeholder that can be looked up in the document's image list.
+func (c *markdownConverter) renderImage(n *html.Node) string {
+  src := strings.TrimSpace(attr(n, "src"))
+  alt := strings.TrimSpace(whitespacePattern.ReplaceAllString(attr(n, "alt"), " "))
+
+  if src == "" || strings.HasPrefix(src, "data:") {
+    return escapeMarkdown(alt)
+  }
+
+  c.images = append(c.images, ContentImage{Alt: alt, URL: c.resolve(src)})
+
+  label := fmt.Sprintf("image %d", len(c.images))
+
+  if alt != "" {
+    label += ": " + alt
+  }
+
+  return escapeMarkdown("[" + label + "]")
+}
+
+func prefixLines(text, prefix, blankPrefix string) string {
+  lines := strings.Split(text, "\n")
+
+  for i, line := range lines {
+    if line == "" {
+      lines[i] = blankPrefix
+    } else {
+      lines[i] = prefix + line
+    }
+  }
+
+  return strings.Join(lines, "\n")
+}
+
+func (c *markdownConverter) renderList(n *html.Node) string {
+  ordered := n.DataAtom == atom.Ol
+
+  index := 1
+
+  if start, err := strconv.Atoi(attr(n, "start")); err == nil && ordered {
+    index = start
+  }
+
+  var items []string
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+      continue
+    }
+
+    content := cleanMarkdown(c.renderChildren(child))
+
+    // Without paragraphs or other blocks the item is tight, so a nested list
+    // should follow its text directly rather than after a blank line.
+    if !hasBlockChildren(child) {
+      content = strings.ReplaceAll(content, "\n\n", "\n")
+    }
+
+    marker := "- "
+
+    if ordered {
+      marker = fmt.Sprintf("%d. ", index)
+      index++
+    }
+
+    indent := strings.Repeat(" ", len(marker))
+
+    lines := strings.Split(content, "\n")
+
+    for i, line := range lines {
+      switch {
+      case i == 0:
+        lines[i] = marker + line
+      case line != "":
+        lines[i] = indent + line
+      }
+    }
+
+    items = append(items, strings.Join(lines, "\n"))
+  }
+
+  if len(items) == 0 {
+    return ""
+  }
+
+  return "\n\n" + strings.Join(items, "\n") + "\n\n"
+}
+
+func (c *markdownConverter) tableRows(n *html.Node) [][]string {
+  var rows [][]string
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type != html.ElementNode {
+      continue
+    }
+
+    switch child.DataAtom {
+    case atom.Thead, atom.Tbody, atom.Tfoot:
+      rows = append(rows, c.tableRows(child)...)
+    case atom.Tr:
+      var cells []string
+
+      for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+        if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+          text := whitespacePattern.ReplaceAllString(cleanMarkdown(c.renderChildren(cell)), " ")
+
+          cells = append(cells, tableCell(text))
+        }
+      }
+
+      if len(cells) > 0 {
+        rows = append(rows, cells)
+      }
+    }
+  }
+
+  return rows
+}
+
+func (c *markdownConverter) renderTable(n *html.Node) string {
+  rows := c.tableRows(n)
+
+  if len(rows) == 0 {
+    return ""
+  }
+
+  columns := 0
+
+  for _, row := range rows {
+    columns = max(columns, len(row))
+  }
+
+  var b strings.Builder
+
+  caption := ""
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type == html.ElementNode && child.DataAtom == atom.Caption {
+      caption = c.renderInline(child)
+    }
+  }
+
+  b.WriteString("\n\n")
+
+  if caption != "" {
+    b.WriteString(wrapInline(caption, "*") + "\n\n")
+  }
+
+  for i, row := range rows {
+    for len(row) < columns {
+      row = append(row, "")
+    }
+
+    b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+
+    if i == 0 {
+      b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+    }
+  }
+
+  b.WriteString("\n")
+
+  return b.String()
+}
+
+func (c *markdownConverter) renderFigure(n *html.Node) string {
+  var body, caption strings.Builder
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type == html.ElementNode && child.DataAtom == atom.Figcaption {
+      caption.WriteString(c.renderInline(child))
+    } else {
+      body.WriteString(c.renderNode(child))
+    }
+  }
+
+  s := "\n\n" + strings.TrimSpace(body.String()) + "\n\n"
+
+  if text := strings.TrimSpace(caption.String()); text != "" {
+    s += wrapInline(text, "*") + "\n\n"
+  }
+
+  return s
+}
+
+// Markdown has no definition lists, so terms become bold paragraphs followed
+// by their definitions. Leading spaces would start a code block, so the
+// definitions are indented with non-breaking spaces instead.
+const definitionIndent = "\u00a0\u00a0\u00a0\u00a0"
+
+func (c *markdownConverter) renderDefinitionList(n *html.Node) string {
+  var b strings.Builder
+
+  b.WriteString("\n\n")
+
+  for child := n.FirstChild; child != nil; child = child.NextSibling {
+    if child.Type != html.ElementNode {
+      continue
+    }
+
+    switch child.DataAtom {
+    case atom.Dt:
+      b.WriteString("\n\n" + wrapInline(c.renderInline(child), "**") + "\n\n")
+    case atom.Dd:
+      b.WriteString("\n\n" + prefixLines(cleanMarkdown(c.renderChildren(child)), definitionIndent, "") + "\n\n")
+    }
+  }
+
+  b.WriteString("\n")
+
+  return b.String()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+  "github.com/charmbracelet/glamour"
+  "github.com/charmbracelet/x/ansi"
+  "slices"
+  "strings"
+  "testing"
+)
+
+func renderPlain(t *testing.T, markdown string) string {
+  t.Helper()
+
+  renderer, err := glamour.NewTermRenderer(glamour.WithStylePath("dark"), glamour.WithWordWrap(200))
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  rendered, err := renderer.Render(markdown)
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  var lines []string
+
+  for _, line := range strings.Split(ansi.Strip(rendered), "\n") {
+    if line = strings.TrimSpace(line); line != "" {
+      lines = append(lines, line)
+    }
+  }
+
+  return strings.Join(lines, "\n")
+}
+
+func TestConvertHTML(t *testing.T) {
+  tests := []struct {
+    name  string
+    input string
+    want  string
+  }{
+    {name: "empty", input: "", want: ""},
+    {name: "paragraphs", input: "<p>One</p><p>Two</p>", want: "One\n\nTwo"},
+    {name: "heading", input: "<h2>Title</h2><p>Body</p>", want: "## Title\n\nBody"},
+    {name: "emphasis", input: "<p><strong>bold </strong>and <em>italic</em></p>", want: "**bold** and *italic*"},
+    {name: "skipped elements", input: "<p>Text</p><script>alert(1)</script><style>p{}</style>", want: "Text"},
+    {name: "inline code is not escaped", input: "<p><code>a_b*c</code></p>", want: "`a_b*c`"},
+    {name: "code block", input: `<pre><code class="language-go">x := *p</code></pre>`, want: "```go\nx := *p\n```"},
+    {name: "unordered list", input: "<ul><li>One</li><li>Two</li></ul>", want: "- One\n- Two"},
+    {name: "ordered list with start", input: `<ol start="3"><li>Three</li><li>Four</li></ol>`, want: "3. Three\n4. Four"},
+    {name: "blockquote", input: "<blockquote><p>Quoted</p></blockquote>", want: "> Quoted"},
+    {name: "link", input: `<p>See <a href="https://example.com">this</a>.</p>`, want: "See this⟦1⟧."},
+    {name: "escaped emphasis", input: "<p>2 * 3 and snake_case</p>", want: "2 &#42; 3 and snake&#95;case"},
+    {name: "escaped list marker", input: "<p>1. Not a list</p>", want: "1&#46; Not a list"},
+    {name: "escaped heading marker", input: "<p># not heading</p>", want: "&#35; not heading"},
+    {name: "escaped link syntax", input: "<p>[x](y)</p>", want: "&#91;x&#93;(y)"},
+    {name: "escaped html", input: "<p>a &lt;b&gt; &amp;amp;</p>", want: "a &lt;b&gt; &amp;amp;"},
+    {name: "definition list", input: "<dl><dt>Term</dt><dd>Meaning</dd></dl>", want: "**Term**\n\n" + definitionIndent + "Meaning"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      if got := htmlToMarkdown(test.input); got != test.want {
+        t.Errorf("htmlToMarkdown(%q) = %q, want %q", test.input, got, test.want)
+      }
+    })
+  }
+}
+
+// Escaped text has to come out of glamour exactly as it was written in the
+// article, without markdown formatting and without backslashes.
+func TestConvertHTMLRendersLiterally(t *testing.T) {
+  tests := []struct {
+    input string
+    want  string
+  }{
+    {"<p>1. Not a list</p>", "1. Not a list"},
+    {"<p>2) Not a list either</p>", "2) Not a list either"},
+    {"<p># not heading</p>", "# not heading"},
+    {"<p>- not a bullet</p>", "- not a bullet"},
+    {"<p>+ not a bullet</p>", "+ not a bullet"},
+    {"<p>&gt; not a quote</p>", "> not a quote"},
+    {"<p>[x](y)</p>", "[x](y)"},
+    {"<p>*not italic* and _not italic_</p>", "*not italic* and _not italic_"},
+    {"<p>`not code` \\ backslash</p>", "`not code` \\ backslash"},
+    {"<p>&lt;not-html&gt; &amp;copy;</p>", "<not-html> &copy;"},
+    {"<p>line<br>=====</p>", "line ====="},
+  }
+
+  for _, test := range tests {
+    t.Run(test.input, func(t *testing.T) {
+      if got := renderPlain(t, htmlToMarkdown(test.input)); got != test.want {
+        t.Errorf("rendered %q, want %q", got, test.want)
+      }
+    })
+  }
+}
+
+func TestConvertHTMLTableCells(t *testing.T) {
+  rendered := renderPlain(t, htmlToMarkdown("<table><tr><th>Key</th><th>Value</th></tr><tr><td>A|X</td><td>2 * 3 &lt;b&gt;</td></tr></table>"))
+
+  rows := strings.Split(rendered, "\n")
+
+  if len(rows) != 3 {
+    t.Fatalf("expected a header, a rule and one row, got %q", rendered)
+  }
+
+  cells := strings.Split(rows[2], "│")
+
+  if len(cells) != 2 || strings.TrimSpace(cells[0]) != "A∣X" || strings.ReplaceAll(strings.TrimSpace(cells[1]), "\u200b", "") != "2 * 3 <b>" {
+    t.Errorf("expected the cells A∣X and 2 * 3 <b>, got %q", rows[2])
+  }
+}
+
+func TestConvertHTMLCollectsLinksAndImages(t *testing.T) {
+  converted := convertHTML(`<p><a href="/a">A</a> <a href="/b">B</a> <a href="/a">again</a> <img src="/i.png" alt="Pic"></p>`, "https://example.com/post")
+
+  links := make([]string, len(converted.Links))
+
+  for i, link := range converted.Links {
+    links[i] = link.URL
+  }
+
+  if want := []string{"https://example.com/a", "https://example.com/b"}; !slices.Equal(links, want) {
+    t.Errorf("links = %v, want %v", links, want)
+  }
+
+  if len(converted.Images) != 1 || converted.Images[0].URL != "https://example.com/i.png" {
+    t.Errorf("images = %v", converted.Images)
+  }
+
+  if want := "A⟦1⟧ B⟦2⟧ again⟦1⟧ &#91;image 1: Pic&#93;"; converted.Markdown != want {
+    t.Errorf("markdown = %q, want %q", converted.Markdown, want)
+  }
+}
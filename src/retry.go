@@ -0,0 +1,105 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  tea "github.com/charmbracelet/bubbletea"
+  "math/rand/v2"
+  "net"
+  "net/http"
+  "strconv"
+  "time"
+)
+
+const (
+  maxAttempts = 5
+  maxBackoff  = time.Minute
+)
+
+type RetryNotice struct {
+  Attempt int
+  Reason  string
+  Wait    time.Duration
+}
+
+type retryMsg RetryNotice
+
+func (n RetryNotice) String() string {
+  return fmt.Sprintf("Waiting %s for %s (attempt %d/%d)...", n.Wait.Round(time.Second), n.Reason, n.Attempt+1, maxAttempts)
+}
+
+func backoff(attempt int) time.Duration {
+  wait := min(time.Second<<(attempt-1), maxBackoff)
+
+  return wait + rand.N(wait/4+1)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+  if value == "" {
+    return 0, false
+  }
+
+  if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+    return time.Duration(seconds) * time.Second, true
+  }
+
+  if at, err := http.ParseTime(value); err == nil {
+    return max(time.Until(at), 0), true
+  }
+
+  return 0, false
+}
+
+// Only reads are retried whenever they fail. Requests that change something
+// may already have been applied when the response is lost, so they are only
+// retried when they never reached the server or were rate limited, which
+// Reader does before doing any work.
+func retryDelay(method string, resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+  safe := method == http.MethodGet || method == http.MethodHead
+
+  switch {
+  case err != nil && (safe || !requestSent(err)):
+    return backoff(attempt), "network error", true
+  case err != nil:
+    return 0, "", false
+  case resp.StatusCode == http.StatusTooManyRequests:
+    if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+      return wait, "rate limit", true
+    }
+
+    return backoff(attempt), "rate limit", true
+  case resp.StatusCode >= http.StatusInternalServerError && safe:
+    return backoff(attempt), fmt.Sprintf("server error %d", resp.StatusCode), true
+  default:
+    return 0, "", false
+  }
+}
+
+// Failing to resolve or connect to the host means nothing was sent. Any other
+// error may have happened after the server received the request.
+func requestSent(err error) bool {
+  var dnsErr *net.DNSError
+
+  if errors.As(err, &dnsErr) {
+    return false
+  }
+
+  var opErr *net.OpError
+
+  return !errors.As(err, &opErr) || opErr.Op != "dial"
+}
+
+func notifyRetries(retries chan<- RetryNotice) func(RetryNotice) {
+  return func(notice RetryNotice) {
+    select {
+    case retries <- notice:
+    default:
+    }
+  }
+}
+
+func listenForRetries(retries <-chan RetryNotice) tea.Cmd {
+  return func() tea.Msg {
+    return retryMsg(<-retries)
+  }
+}
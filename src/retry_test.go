@@ -0,0 +1,101 @@
+package main
+
+import (
+  "errors"
+  "net"
+  "net/http"
+  "testing"
+  "time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+  tests := []struct {
+    name  string
+    value string
+    want  time.Duration
+    ok    bool
+  }{
+    {name: "empty", value: "", ok: false},
+    {name: "seconds", value: "30", want: 30 * time.Second, ok: true},
+    {name: "zero", value: "0", want: 0, ok: true},
+    {name: "negative", value: "-5", ok: false},
+    {name: "past date", value: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, ok: true},
+    {name: "garbage", value: "soon", ok: false},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      wait, ok := parseRetryAfter(test.value)
+
+      if ok != test.ok || wait != test.want {
+        t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", test.value, wait, ok, test.want, test.ok)
+      }
+    })
+  }
+
+  future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+
+  if wait, ok := parseRetryAfter(future); !ok || wait <= 0 || wait > time.Minute {
+    t.Errorf("parseRetryAfter(%q) = %v, %v", future, wait, ok)
+  }
+}
+
+func TestRetryDelay(t *testing.T) {
+  response := func(status int, retryAfter string) *http.Response {
+    resp := &http.Response{StatusCode: status, Header: http.Header{}}
+
+    if retryAfter != "" {
+      resp.Header.Set("Retry-After", retryAfter)
+    }
+
+    return resp
+  }
+
+  dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+  readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}
+
+  tests := []struct {
+    name   string
+    method string
+    resp   *http.Response
+    err    error
+    retry  bool
+    wait   time.Duration
+  }{
+    {name: "success", method: "GET", resp: response(200, "")},
+    {name: "client error", method: "GET", resp: response(404, "")},
+    {name: "rate limit with retry-after", method: "GET", resp: response(429, "7"), retry: true, wait: 7 * time.Second},
+    {name: "rate limited write", method: "POST", resp: response(429, "3"), retry: true, wait: 3 * time.Second},
+    {name: "server error read", method: "GET", resp: response(503, ""), retry: true},
+    {name: "server error write", method: "PATCH", resp: response(503, "")},
+    {name: "network error read", method: "GET", err: readErr, retry: true},
+    {name: "network error write", method: "DELETE", err: readErr},
+    {name: "dial error write", method: "DELETE", err: dialErr, retry: true},
+    {name: "dns error write", method: "POST", err: &net.DNSError{Err: "no such host"}, retry: true},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      wait, _, retry := retryDelay(test.method, test.resp, test.err, 1)
+
+      if retry != test.retry {
+        t.Fatalf("retry = %v, want %v", retry, test.retry)
+      }
+
+      if test.wait > 0 && wait != test.wait {
+        t.Errorf("wait = %v, want %v", wait, test.wait)
+      }
+    })
+  }
+}
+
+func TestBackoff(t *testing.T) {
+  for attempt := 1; attempt <= 10; attempt++ {
+    base := min(time.Second<<(attempt-1), maxBackoff)
+
+    if wait := backoff(attempt); wait < base || wait > base+base/4 {
+      t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, wait, base, base+base/4)
+    }
+  }
+}
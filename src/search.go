@@ -0,0 +1,228 @@
+package main
+
+import (
+  tea "github.com/charmbracelet/bubbletea"
+  "html"
+  "net/url"
+  "slices"
+  "sort"
+  "strings"
+  "unicode"
+)
+
+type SearchIndex struct {
+  content  map[string]indexedContent
+  postings map[string]map[string]bool
+  terms    []string
+}
+
+type indexedContent struct {
+  source string
+  tokens []string
+}
+
+type searchIndexBuiltMsg *SearchIndex
+
+func tokenize(text string) []string {
+  return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+  })
+}
+
+func documentSite(doc Document) string {
+  for _, raw := range []string{doc.SourceURL, doc.URL} {
+    if parsed, err := url.Parse(raw); err == nil && parsed.Host != "" {
+      return strings.TrimPrefix(parsed.Host, "www.")
+    }
+  }
+
+  return ""
+}
+
+// Converting document content to text is by far the most expensive part of
+// indexing, so the tokens of every document whose content is unchanged since
+// the previous index are reused rather than converted again.
+func NewSearchIndex(documents []Document, previous *SearchIndex) *SearchIndex {
+  index := &SearchIndex{
+    content:  make(map[string]indexedContent, len(documents)),
+    postings: make(map[string]map[string]bool),
+  }
+
+  for _, doc := range documents {
+    content := index.contentTokens(doc, previous)
+
+    tokens := tokenize(strings.Join([]string{doc.Title, doc.Author, documentSite(doc), strings.Join(doc.Tags.Names(), " ")}, " "))
+
+    for _, token := range append(tokens, content...) {
+      ids, exists := index.postings[token]
+
+      if !exists {
+        ids = make(map[string]bool)
+        index.postings[token] = ids
+        index.terms = append(index.terms, token)
+      }
+
+      ids[doc.ID] = true
+    }
+  }
+
+  sort.Strings(index.terms)
+
+  return index
+}
+
+func (s *SearchIndex) contentTokens(doc Document, previous *SearchIndex) []string {
+  source := doc.HTMLContent
+
+  if source == "" {
+    source = doc.Summary
+  }
+
+  if previous != nil {
+    if cached, ok := previous.content[doc.ID]; ok && cached.source == source {
+      s.content[doc.ID] = cached
+      return cached.tokens
+    }
+  }
+
+  text := source
+
+  if doc.HTMLContent != "" {
+    text = html.UnescapeString(htmlToMarkdown(doc.HTMLContent))
+  }
+
+  seen := make(map[string]bool)
+
+  var tokens []string
+
+  for _, token := range tokenize(text) {
+    if !seen[token] {
+      seen[token] = true
+      tokens = append(tokens, token)
+    }
+  }
+
+  s.content[doc.ID] = indexedContent{source: source, tokens: tokens}
+
+  return tokens
+}
+
+// Search returns the IDs of documents containing every query term. The last
+// term is matched as a prefix so results narrow while the query is typed.
+func (s *SearchIndex) Search(query string) map[string]bool {
+  terms := tokenize(query)
+
+  if len(terms) == 0 {
+    return nil
+  }
+
+  var result map[string]bool
+
+  for i, term := range terms {
+    matches := make(map[string]bool)
+
+    if i == len(terms)-1 {
+      start := sort.SearchStrings(s.terms, term)
+
+      for _, candidate := range s.terms[start:] {
+        if !strings.HasPrefix(candidate, term) {
+          break
+        }
+
+        for id := range s.postings[candidate] {
+          matches[id] = true
+        }
+      }
+    } else {
+      for id := range s.postings[term] {
+        matches[id] = true
+      }
+    }
+
+    if result == nil {
+      result = matches
+      continue
+    }
+
+    for id := range result {
+      if !matches[id] {
+        delete(result, id)
+      }
+    }
+  }
+
+  return result
+}
+
+func matchesDocument(doc Document, query string) bool {
+  haystack := strings.ToLower(strings.Join([]string{doc.Title, doc.Author, documentSite(doc)}, " "))
+
+  for _, term := range tokenize(query) {
+    if !strings.Contains(haystack, term) {
+      return false
+    }
+  }
+
+  return true
+}
+
+func highlightMatches(text, query string) string {
+  terms := tokenize(query)
+
+  lower := strings.ToLower(text)
+
+  // Lowercasing can change byte offsets for some scripts, in which case the
+  // offsets found in `lower` would not line up with `text`.
+  if len(terms) == 0 || len(lower) != len(text) {
+    return text
+  }
+
+  marked := make([]bool, len(text))
+
+  for _, term := range terms {
+    for offset := 0; ; {
+      i := strings.Index(lower[offset:], term)
+
+      if i < 0 {
+        break
+      }
+
+      for j := offset + i; j < offset+i+len(term); j++ {
+        marked[j] = true
+      }
+
+      offset += i + len(term)
+    }
+  }
+
+  var b strings.Builder
+
+  for start := 0; start < len(text); {
+    end := start
+
+    for end < len(text) && marked[end] == marked[start] {
+      end++
+    }
+
+    if marked[start] {
+      b.WriteString(styles.Match.Render(text[start:end]))
+    } else {
+      b.WriteString(text[start:end])
+    }
+
+    start = end
+  }
+
+  return b.String()
+}
+
+// The index is built in the background, so it gets its own copy of the
+// documents rather than the slice the update loop keeps modifying. A finished
+// index is never modified, which makes it safe to build the next one from.
+func buildSearchIndex(documents []Document, previous *SearchIndex) tea.Cmd {
+  documents = slices.Clone(documents)
+
+  return func() tea.Msg {
+    return searchIndexBuiltMsg(NewSearchIndex(documents, previous))
+  }
+}
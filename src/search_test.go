@@ -0,0 +1,77 @@
+package main
+
+import (
+  "maps"
+  "slices"
+  "testing"
+)
+
+func TestSearchIndexSearch(t *testing.T) {
+  index := NewSearchIndex([]Document{
+    {ID: "go", Title: "Concurrency in Go", Author: "Rob Pike", SourceURL: "https://www.go.dev/blog"},
+    {ID: "rust", Title: "Fearless Concurrency", Summary: "Ownership and borrowing"},
+    {ID: "tea", Title: "Bubble Tea", HTMLContent: "<p>Building <em>terminal</em> apps</p>"},
+    {ID: "tagged", Title: "Untitled", Tags: newTags([]string{"reading-list"})},
+  }, nil)
+
+  tests := []struct {
+    query string
+    want  []string
+  }{
+    {"", nil},
+    {"concurrency", []string{"go", "rust"}},
+    {"CONCURRENCY go", []string{"go"}},
+    {"concur", []string{"go", "rust"}},
+    {"pike", []string{"go"}},
+    {"go.dev", []string{"go"}},
+    {"borrowing", []string{"rust"}},
+    {"terminal", []string{"tea"}},
+    {"reading", []string{"tagged"}},
+    {"missing", []string{}},
+    {"concurrency missing", []string{}},
+  }
+
+  for _, test := range tests {
+    t.Run(test.query, func(t *testing.T) {
+      result := index.Search(test.query)
+
+      if test.want == nil {
+        if result != nil {
+          t.Errorf("Search(%q) = %v, want nil", test.query, result)
+        }
+
+        return
+      }
+
+      if ids := slices.Sorted(maps.Keys(result)); !slices.Equal(ids, test.want) {
+        t.Errorf("Search(%q) = %v, want %v", test.query, ids, test.want)
+      }
+    })
+  }
+}
+
+func TestSearchIndexReusesUnchangedContent(t *testing.T) {
+  previous := NewSearchIndex([]Document{
+    {ID: "a", HTMLContent: "<p>original text</p>"},
+    {ID: "b", HTMLContent: "<p>kept text</p>"},
+  }, nil)
+
+  index := NewSearchIndex([]Document{
+    {ID: "a", Title: "Renamed", HTMLContent: "<p>changed text</p>"},
+    {ID: "b", HTMLContent: "<p>kept text</p>"},
+  }, previous)
+
+  if len(index.Search("original")) != 0 {
+    t.Error("stale content is still indexed")
+  }
+
+  for _, query := range []string{"changed", "renamed", "kept"} {
+    if len(index.Search(query)) != 1 {
+      t.Errorf("Search(%q) found nothing", query)
+    }
+  }
+
+  if &index.content["b"].tokens[0] != &previous.content["b"].tokens[0] {
+    t.Error("unchanged content was converted again")
+  }
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+  "fmt"
+  tea "github.com/charmbracelet/bubbletea"
+  "sort"
+  "strings"
+  "sync"
+  "sync/atomic"
+)
+
+type configSaveFailedMsg struct {
+  err error
+}
+
+var sortModes = []string{"saved", "published", "updated", "title", "author", "words", "progress"}
+
+var sortNames = map[string]string{
+  "saved":     "Saved",
+  "published": "Published",
+  "updated":   "Updated",
+  "title":     "Title",
+  "author":    "Author",
+  "words":     "Words",
+  "progress":  "Progress",
+}
+
+func validateSort(mode, order string) error {
+  if _, ok := sortNames[mode]; mode != "" && !ok {
+    return fmt.Errorf("unknown sort '%s': expected one of %s", mode, strings.Join(sortModes, ", "))
+  }
+
+  if order != "" && order != "asc" && order != "desc" {
+    return fmt.Errorf("unknown sort order '%s': expected asc or desc", order)
+  }
+
+  return nil
+}
+
+func nextSortMode(mode string) string {
+  for i, candidate := range sortModes {
+    if candidate == mode {
+      return sortModes[(i+1)%len(sortModes)]
+    }
+  }
+
+  return sortModes[0]
+}
+
+func sortDocuments(documents []Document, mode string, descending bool) {
+  less := func(a, b Document) bool {
+    switch mode {
+    case "published":
+      return a.PublishedDate.Before(b.PublishedDate.Time)
+    case "updated":
+      return a.UpdatedAt.Before(b.UpdatedAt.Time)
+    case "title":
+      return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+    case "author":
+      return strings.ToLower(a.Author) < strings.ToLower(b.Author)
+    case "words":
+      return a.WordCount < b.WordCount
+    case "progress":
+      return a.ReadingProgress < b.ReadingProgress
+    default:
+      return a.SavedAt.Before(b.SavedAt.Time)
+    }
+  }
+
+  sort.SliceStable(documents, func(i, j int) bool {
+    if descending {
+      return less(documents[j], documents[i])
+    }
+
+    return less(documents[i], documents[j])
+  })
+}
+
+var sortSaves struct {
+  sync.Mutex
+  started atomic.Int64
+  saved   int64
+}
+
+// Quick presses of the sort keys start several saves at once. They are
+// numbered in the order the keys were pressed and written one at a time, and
+// a save that runs after a later one is skipped, so the last choice sticks.
+func saveSortConfig(config Config) tea.Cmd {
+  number := sortSaves.started.Add(1)
+
+  return func() tea.Msg {
+    sortSaves.Lock()
+    defer sortSaves.Unlock()
+
+    if number < sortSaves.saved {
+      return nil
+    }
+
+    sortSaves.saved = number
+
+    if err := saveConfig(&config); err != nil {
+      return configSaveFailedMsg{err: err}
+    }
+
+    return nil
+  }
+}
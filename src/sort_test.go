@@ -0,0 +1,117 @@
+package main
+
+import (
+  tea "github.com/charmbracelet/bubbletea"
+  "slices"
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestSortDocuments(t *testing.T) {
+  day := func(n int) Timestamp {
+    return Timestamp{time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC)}
+  }
+
+  docs := []Document{
+    {ID: "a", Title: "beta", Author: "Zoe", SavedAt: day(2), PublishedDate: day(3), UpdatedAt: day(1), WordCount: 300, ReadingProgress: 0.5},
+    {ID: "b", Title: "Alpha", Author: "amy", SavedAt: day(3), PublishedDate: day(1), UpdatedAt: day(2), WordCount: 100, ReadingProgress: 0.5},
+    {ID: "c", Title: "gamma", Author: "Bob", SavedAt: day(1), PublishedDate: day(2), UpdatedAt: day(3), WordCount: 200, ReadingProgress: 0.1},
+  }
+
+  tests := []struct {
+    mode       string
+    descending bool
+    ids        []string
+  }{
+    {"saved", false, []string{"c", "a", "b"}},
+    {"saved", true, []string{"b", "a", "c"}},
+    {"published", false, []string{"b", "c", "a"}},
+    {"updated", true, []string{"c", "b", "a"}},
+    {"title", false, []string{"b", "a", "c"}},
+    {"author", false, []string{"b", "c", "a"}},
+    {"words", true, []string{"a", "c", "b"}},
+    {"progress", false, []string{"c", "a", "b"}},
+    {"progress", true, []string{"a", "b", "c"}},
+    {"unknown", false, []string{"c", "a", "b"}},
+  }
+
+  for _, test := range tests {
+    order := "asc"
+
+    if test.descending {
+      order = "desc"
+    }
+
+    t.Run(test.mode+"/"+order, func(t *testing.T) {
+      sorted := slices.Clone(docs)
+
+      sortDocuments(sorted, test.mode, test.descending)
+
+      if ids := documentIDs(sorted); !slices.Equal(ids, test.ids) {
+        t.Errorf("expected %v, got %v", test.ids, ids)
+      }
+    })
+  }
+}
+
+func TestValidateSort(t *testing.T) {
+  tests := []struct {
+    mode  string
+    order string
+    valid bool
+  }{
+    {"", "", true},
+    {"title", "asc", true},
+    {"words", "desc", true},
+    {"size", "", false},
+    {"", "up", false},
+  }
+
+  for _, test := range tests {
+    if err := validateSort(test.mode, test.order); (err == nil) != test.valid {
+      t.Errorf("validateSort(%q, %q) = %v, expected valid %v", test.mode, test.order, err, test.valid)
+    }
+  }
+}
+
+func TestSaveSortConfigKeepsLastChoice(t *testing.T) {
+  t.Setenv("HOME", t.TempDir())
+
+  var saves []tea.Cmd
+
+  for _, mode := range sortModes {
+    saves = append(saves, saveSortConfig(Config{Sort: mode, Token: "token"}))
+  }
+
+  var wg sync.WaitGroup
+
+  // The last save runs first, and the rest race each other after it.
+  if msg := saves[len(saves)-1](); msg != nil {
+    t.Fatalf("failed to save: %v", msg)
+  }
+
+  for _, save := range saves[:len(saves)-1] {
+    wg.Add(1)
+
+    go func() {
+      defer wg.Done()
+
+      if msg := save(); msg != nil {
+        t.Errorf("failed to save: %v", msg)
+      }
+    }()
+  }
+
+  wg.Wait()
+
+  config, err := loadConfig()
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if last := sortModes[len(sortModes)-1]; config.Sort != last || config.Token != "token" {
+    t.Errorf("expected the last sort %q to be saved with the token, got %+v", last, config)
+  }
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  tea "github.com/charmbracelet/bubbletea"
+  "sort"
+  "strings"
+)
+
+type Tag struct {
+  Name    string `json:"name"`
+  Type    string `json:"type,omitempty"`
+  Created int64  `json:"created,omitempty"`
+}
+
+type Tags map[string]Tag
+
+type TagCount struct {
+  Name  string
+  Count int
+}
+
+type documentTagsUpdatedMsg struct {
+  id string
+}
+
+type documentTagsUpdateFailedMsg struct {
+  id       string
+  tags     Tags
+  previous Tags
+  err      error
+}
+
+// Reader sends tags as an object keyed by tag name, but null and an empty
+// list show up for untagged documents.
+func (t *Tags) UnmarshalJSON(data []byte) error {
+  data = bytes.TrimSpace(data)
+
+  if bytes.Equal(data, []byte("null")) || bytes.Equal(data, []byte("[]")) {
+    *t = nil
+    return nil
+  }
+
+  var tags map[string]Tag
+
+  if err := json.Unmarshal(data, &tags); err != nil {
+    return fmt.Errorf("failed to decode tags: %w", err)
+  }
+
+  for key, tag := range tags {
+    if tag.Name == "" {
+      tag.Name = key
+      tags[key] = tag
+    }
+  }
+
+  *t = tags
+
+  return nil
+}
+
+func (t Tags) Names() []string {
+  names := make([]string, 0, len(t))
+
+  for _, tag := range t {
+    names = append(names, tag.Name)
+  }
+
+  sort.Strings(names)
+
+  return names
+}
+
+func (t Tags) Has(name string) bool {
+  for _, tag := range t {
+    if tag.Name == name {
+      return true
+    }
+  }
+
+  return false
+}
+
+func (t Tags) String() string {
+  names := t.Names()
+
+  for i, name := range names {
+    names[i] = "#" + name
+  }
+
+  return strings.Join(names, " ")
+}
+
+func newTags(names []string) Tags {
+  if len(names) == 0 {
+    return nil
+  }
+
+  tags := make(Tags, len(names))
+
+  for _, name := range names {
+    tags[name] = Tag{Name: name, Type: "manual"}
+  }
+
+  return tags
+}
+
+func buildTagCounts(documents []Document) []TagCount {
+  counts := make(map[string]int)
+
+  for _, doc := range documents {
+    for _, name := range doc.Tags.Names() {
+      counts[name]++
+    }
+  }
+
+  tagCounts := make([]TagCount, 0, len(counts))
+
+  for name, count := range counts {
+    tagCounts = append(tagCounts, TagCount{Name: name, Count: count})
+  }
+
+  sort.Slice(tagCounts, func(i, j int) bool {
+    return tagCounts[i].Name < tagCounts[j].Name
+  })
+
+  return tagCounts
+}
+
+func updateDocumentTags(ctx context.Context, api *ReaderAPI, cache *DocumentCache, doc Document, previous Tags) tea.Cmd {
+  return func() tea.Msg {
+    names := doc.Tags.Names()
+
+    if err := api.UpdateDocument(ctx, doc.ID, DocumentUpdate{Tags: &names}); err != nil {
+      return documentTagsUpdateFailedMsg{id: doc.ID, tags: doc.Tags, previous: previous, err: err}
+    }
+
+    if err := cache.Put(doc); err != nil {
+      return cacheWriteFailedMsg{err: err}
+    }
+
+    return documentTagsUpdatedMsg{id: doc.ID}
+  }
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+  "encoding/json"
+  "slices"
+  "testing"
+)
+
+func TestTagsUnmarshalJSON(t *testing.T) {
+  tests := []struct {
+    name    string
+    input   string
+    want    []string
+    wantErr bool
+  }{
+    {name: "null", input: `null`, want: []string{}},
+    {name: "empty list", input: `[]`, want: []string{}},
+    {name: "empty object", input: `{}`, want: []string{}},
+    {name: "object", input: `{"go": {"name": "go", "type": "manual"}, "tui": {"name": "tui"}}`, want: []string{"go", "tui"}},
+    {name: "name from key", input: `{"reading-list": {"type": "manual"}}`, want: []string{"reading-list"}},
+    {name: "invalid", input: `"go"`, wantErr: true},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      var tags Tags
+
+      err := json.Unmarshal([]byte(test.input), &tags)
+
+      if test.wantErr {
+        if err == nil {
+          t.Errorf("expected an error, got %v", tags)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if names := tags.Names(); !slices.Equal(names, test.want) {
+        t.Errorf("names = %v, want %v", names, test.want)
+      }
+    })
+  }
+}
+
+func TestTagsString(t *testing.T) {
+  tags := newTags([]string{"tui", "go"})
+
+  if got, want := tags.String(), "#go #tui"; got != want {
+    t.Errorf("String() = %q, want %q", got, want)
+  }
+
+  if !tags.Has("go") || tags.Has("rust") {
+    t.Errorf("Has reported the wrong tags for %v", tags.Names())
+  }
+}
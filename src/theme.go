@@ -0,0 +1,224 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/charmbracelet/lipgloss"
+  "github.com/charmbracelet/x/ansi"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+type ThemeColor lipgloss.AdaptiveColor
+
+type Theme struct {
+  Accent        ThemeColor `json:"accent"`
+  Glamour       string     `json:"glamour"`
+  Highlight     ThemeColor `json:"highlight"`
+  HighlightText ThemeColor `json:"highlight_text"`
+  Muted         ThemeColor `json:"muted"`
+  Selected      ThemeColor `json:"selected"`
+  SelectedText  ThemeColor `json:"selected_text"`
+  Status        ThemeColor `json:"status"`
+  StatusText    ThemeColor `json:"status_text"`
+}
+
+type Styles struct {
+  ActiveTab lipgloss.Style
+  Cursor    lipgloss.Style
+  Highlight lipgloss.Style
+  Mark      lipgloss.Style
+  Match     lipgloss.Style
+  Muted     lipgloss.Style
+  Selected  lipgloss.Style
+  StatusBar lipgloss.Style
+  Tab       lipgloss.Style
+  Title     lipgloss.Style
+}
+
+func color(light, dark string) ThemeColor {
+  return ThemeColor{Light: light, Dark: dark}
+}
+
+// The mono theme has no colors at all, which newStyles turns into reverse
+// video and underlines. It is also what NO_COLOR selects.
+var themes = map[string]Theme{
+  "default": {
+    Accent:        color("#5A56E0", "#7D79F6"),
+    Glamour:       "auto",
+    Highlight:     color("#FFF3A3", "#5C4A00"),
+    HighlightText: color("#000000", "#FFFFFF"),
+    Muted:         color("#8A8A8A", "#6C6C6C"),
+    Selected:      color("#E4E2FF", "#3B3870"),
+    SelectedText:  color("#1A1A1A", "#FFFFFF"),
+    Status:        color("#E8E8E8", "#262626"),
+    StatusText:    color("#4A4A4A", "#BCBCBC"),
+  },
+  "dracula": {
+    Accent:        color("#BD93F9", "#BD93F9"),
+    Glamour:       "dracula",
+    Highlight:     color("#F1FA8C", "#F1FA8C"),
+    HighlightText: color("#282A36", "#282A36"),
+    Muted:         color("#6272A4", "#6272A4"),
+    Selected:      color("#44475A", "#44475A"),
+    SelectedText:  color("#F8F8F2", "#F8F8F2"),
+    Status:        color("#21222C", "#21222C"),
+    StatusText:    color("#F8F8F2", "#F8F8F2"),
+  },
+  "nord": {
+    Accent:        color("#5E81AC", "#88C0D0"),
+    Glamour:       "auto",
+    Highlight:     color("#EBCB8B", "#EBCB8B"),
+    HighlightText: color("#2E3440", "#2E3440"),
+    Muted:         color("#7B88A1", "#616E88"),
+    Selected:      color("#D8DEE9", "#3B4252"),
+    SelectedText:  color("#2E3440", "#ECEFF4"),
+    Status:        color("#E5E9F0", "#2E3440"),
+    StatusText:    color("#4C566A", "#D8DEE9"),
+  },
+  "mono": {
+    Glamour: "notty",
+  },
+}
+
+// Theme colors are either a single color for every terminal or an object
+// with separate colors for light and dark backgrounds.
+func (c *ThemeColor) UnmarshalJSON(data []byte) error {
+  var single string
+
+  if err := json.Unmarshal(data, &single); err == nil {
+    *c = ThemeColor{Light: single, Dark: single}
+    return nil
+  }
+
+  var adaptive struct {
+    Light string `json:"light"`
+    Dark  string `json:"dark"`
+  }
+
+  if err := json.Unmarshal(data, &adaptive); err != nil {
+    return fmt.Errorf("failed to decode theme color: %w", err)
+  }
+
+  *c = ThemeColor(adaptive)
+
+  return nil
+}
+
+func (c ThemeColor) isSet() bool {
+  return c.Light != "" || c.Dark != ""
+}
+
+func noColor() bool {
+  return os.Getenv("NO_COLOR") != ""
+}
+
+// Custom themes live in the themes directory of the config directory and
+// start from the default theme, so they only need the colors they change.
+func loadTheme(name string) (Theme, error) {
+  if noColor() {
+    return themes["mono"], nil
+  }
+
+  if name == "" {
+    name = "default"
+  }
+
+  if theme, ok := themes[name]; ok {
+    return theme, nil
+  }
+
+  if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+    return Theme{}, fmt.Errorf("invalid theme name '%s': expected a built-in theme or a file name in the themes directory", name)
+  }
+
+  configDir, err := getConfigDir()
+
+  if err != nil {
+    return Theme{}, err
+  }
+
+  data, err := os.ReadFile(filepath.Join(configDir, "themes", name+".json"))
+
+  if os.IsNotExist(err) {
+    names := make([]string, 0, len(themes))
+
+    for name := range themes {
+      names = append(names, name)
+    }
+
+    sort.Strings(names)
+
+    return Theme{}, fmt.Errorf("unknown theme '%s': expected one of %s, or a file in %s", name, strings.Join(names, ", "), filepath.Join(configDir, "themes"))
+  }
+
+  if err != nil {
+    return Theme{}, fmt.Errorf("failed to read theme: %w", err)
+  }
+
+  theme := themes["default"]
+
+  if err := json.Unmarshal(data, &theme); err != nil {
+    return Theme{}, fmt.Errorf("failed to parse theme '%s': %w", name, err)
+  }
+
+  return theme, nil
+}
+
+func newStyles(theme Theme) Styles {
+  adaptive := func(c ThemeColor) lipgloss.AdaptiveColor {
+    return lipgloss.AdaptiveColor(c)
+  }
+
+  s := Styles{
+    ActiveTab: lipgloss.NewStyle().Bold(true).Underline(true),
+    Cursor:    lipgloss.NewStyle().Bold(true),
+    Highlight: lipgloss.NewStyle().Underline(true),
+    Mark:      lipgloss.NewStyle().Reverse(true),
+    Match:     lipgloss.NewStyle().Reverse(true),
+    Muted:     lipgloss.NewStyle().Faint(true),
+    Selected:  lipgloss.NewStyle().Reverse(true),
+    StatusBar: lipgloss.NewStyle().Reverse(true),
+    Tab:       lipgloss.NewStyle(),
+    Title:     lipgloss.NewStyle().Bold(true),
+  }
+
+  if theme.Accent.isSet() {
+    s.ActiveTab = s.ActiveTab.Foreground(adaptive(theme.Accent))
+    s.Cursor = s.Cursor.Foreground(adaptive(theme.Accent))
+    s.Title = s.Title.Foreground(adaptive(theme.Accent))
+  }
+
+  if theme.Muted.isSet() {
+    s.Muted = lipgloss.NewStyle().Foreground(adaptive(theme.Muted))
+    s.Tab = s.Tab.Foreground(adaptive(theme.Muted))
+  }
+
+  if theme.Highlight.isSet() {
+    s.Highlight = lipgloss.NewStyle().Background(adaptive(theme.Highlight)).Foreground(adaptive(theme.HighlightText))
+  }
+
+  if theme.Selected.isSet() {
+    s.Selected = lipgloss.NewStyle().Background(adaptive(theme.Selected)).Foreground(adaptive(theme.SelectedText))
+  }
+
+  if theme.Status.isSet() {
+    s.StatusBar = lipgloss.NewStyle().Background(adaptive(theme.Status)).Foreground(adaptive(theme.StatusText))
+  }
+
+  return s
+}
+
+// Styles are shared by the rendering helpers outside of App, so the theme is
+// applied once at startup before anything is drawn.
+var styles = newStyles(themes["default"])
+
+func (s Styles) row(text string, selected bool) string {
+  if selected {
+    return s.Selected.Render(" " + ansi.Strip(text) + " ")
+  }
+
+  return " " + text + " "
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestLoadTheme(t *testing.T) {
+  home := t.TempDir()
+
+  t.Setenv("HOME", home)
+  t.Setenv("NO_COLOR", "")
+
+  themesDir := filepath.Join(home, ".config", "reader-tui", "themes")
+
+  if err := os.MkdirAll(themesDir, 0755); err != nil {
+    t.Fatal(err)
+  }
+
+  if err := os.WriteFile(filepath.Join(themesDir, "custom.json"), []byte(`{"glamour": "light"}`), 0644); err != nil {
+    t.Fatal(err)
+  }
+
+  tests := []struct {
+    name    string
+    glamour string
+    err     string
+  }{
+    {name: "", glamour: themes["default"].Glamour},
+    {name: "mono", glamour: themes["mono"].Glamour},
+    {name: "custom", glamour: "light"},
+    {name: "missing", err: "unknown theme 'missing'"},
+    {name: "../config", err: "invalid theme name"},
+    {name: "sub/custom", err: "invalid theme name"},
+    {name: `..\config`, err: "invalid theme name"},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      theme, err := loadTheme(test.name)
+
+      if test.err != "" {
+        if err == nil || !strings.Contains(err.Error(), test.err) {
+          t.Fatalf("expected error containing %q, got %v", test.err, err)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if theme.Glamour != test.glamour {
+        t.Errorf("expected glamour style %q, got %q", test.glamour, theme.Glamour)
+      }
+    })
+  }
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "strconv"
+  "time"
+)
+
+type Timestamp struct {
+  time.Time
+}
+
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// Reader is inconsistent about timestamps: most are RFC3339 strings, but
+// published dates can be plain dates or epoch milliseconds, and any of them
+// may be null or empty. A string in any other format is treated as missing
+// rather than failing the whole page of documents it arrived in.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+  data = bytes.TrimSpace(data)
+
+  if bytes.Equal(data, []byte("null")) {
+    *t = Timestamp{}
+    return nil
+  }
+
+  if len(data) > 0 && data[0] == '"' {
+    var value string
+
+    if err := json.Unmarshal(data, &value); err != nil {
+      return fmt.Errorf("failed to decode timestamp: %w", err)
+    }
+
+    *t = parseTimestamp(value)
+
+    return nil
+  }
+
+  number, err := strconv.ParseFloat(string(data), 64)
+
+  if err != nil {
+    return fmt.Errorf("failed to decode timestamp %s: %w", data, err)
+  }
+
+  *t = epochTimestamp(number)
+
+  return nil
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+  if t.IsZero() {
+    return []byte("null"), nil
+  }
+
+  return json.Marshal(t.UTC().Format(time.RFC3339Nano))
+}
+
+func parseTimestamp(value string) Timestamp {
+  for _, layout := range timestampLayouts {
+    if parsed, err := time.Parse(layout, value); err == nil {
+      return Timestamp{parsed}
+    }
+  }
+
+  if number, err := strconv.ParseFloat(value, 64); err == nil {
+    return epochTimestamp(number)
+  }
+
+  return Timestamp{}
+}
+
+// Values this small are seconds rather than milliseconds, as nothing in
+// Reader dates from early 1970.
+func epochTimestamp(value float64) Timestamp {
+  if value <= 0 {
+    return Timestamp{}
+  }
+
+  if value < 1e11 {
+    return Timestamp{time.Unix(int64(value), 0)}
+  }
+
+  return Timestamp{time.UnixMilli(int64(value))}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+  "encoding/json"
+  "testing"
+  "time"
+)
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+  instant := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+
+  tests := []struct {
+    name     string
+    data     string
+    expected time.Time
+    err      bool
+  }{
+    {name: "RFC3339", data: `"2024-03-05T14:30:00Z"`, expected: instant},
+    {name: "RFC3339 with offset", data: `"2024-03-05T15:30:00+01:00"`, expected: instant},
+    {name: "RFC3339 with fraction", data: `"2024-03-05T14:30:00.000000+00:00"`, expected: instant},
+    {name: "date only", data: `"2024-03-05"`, expected: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+    {name: "epoch seconds", data: `1709649000`, expected: instant},
+    {name: "epoch milliseconds", data: `1709649000000`, expected: instant},
+    {name: "epoch milliseconds as string", data: `"1709649000000"`, expected: instant},
+    {name: "null", data: `null`},
+    {name: "empty string", data: `""`},
+    {name: "zero", data: `0`},
+    {name: "unknown format", data: `"next tuesday"`},
+    {name: "invalid", data: `true`, err: true},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      value := Timestamp{time.Now()}
+
+      err := json.Unmarshal([]byte(test.data), &value)
+
+      if test.err {
+        if err == nil {
+          t.Fatalf("expected an error, got %v", value)
+        }
+
+        return
+      }
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if !value.Equal(test.expected) {
+        t.Errorf("expected %v, got %v", test.expected, value.Time)
+      }
+    })
+  }
+}
+
+func TestTimestampMarshalJSON(t *testing.T) {
+  for _, value := range []Timestamp{{}, {time.Date(2024, 3, 5, 14, 30, 0, 123, time.UTC)}} {
+    data, err := json.Marshal(value)
+
+    if err != nil {
+      t.Fatal(err)
+    }
+
+    var decoded Timestamp
+
+    if err := json.Unmarshal(data, &decoded); err != nil {
+      t.Fatal(err)
+    }
+
+    if !decoded.Equal(value.Time) {
+      t.Errorf("expected %s to round-trip to %v, got %v", data, value.Time, decoded.Time)
+    }
+  }
+}
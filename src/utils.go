@@ -1,13 +1,16 @@
 package main
 
 import (
+  "context"
+  "errors"
   "fmt"
   tea "github.com/charmbracelet/bubbletea"
-  "github.com/microcosm-cc/bluemonday"
   "golang.org/x/text/cases"
   "golang.org/x/text/language"
-  "regexp"
+  "slices"
+  "sort"
   "strings"
+  "time"
 )
 
 type Category struct {
@@ -16,6 +19,128 @@ type Category struct {
   Location string
 }
 
+type ReaderCategory struct {
+  Name     string
+  Count    int
+  Category string
+}
+
+var locationNames = map[string]string{
+  "new":       "📥 New",
+  "later":     "🕐 Later",
+  "archive":   "📦 Archive",
+  "feed":      "📰 Feed",
+  "shortlist": "⭐ Shortlist",
+}
+
+func locationName(location string) string {
+  if name, ok := locationNames[location]; ok {
+    return name
+  }
+
+  return cases.Title(language.English).String(location)
+}
+
+// Reader categories are the kind of document, independent of its location.
+var readerCategoryOrder = []string{"article", "email", "rss", "pdf", "epub", "tweet", "video", "note", "highlight"}
+
+var readerCategoryNames = map[string]string{
+  "article":   "Articles",
+  "email":     "Emails",
+  "rss":       "RSS",
+  "pdf":       "PDFs",
+  "epub":      "EPUBs",
+  "tweet":     "Tweets",
+  "video":     "Videos",
+  "note":      "Notes",
+  "highlight": "Highlights",
+}
+
+var readerCategoryIcons = map[string]string{
+  "article":   "📄",
+  "email":     "📧",
+  "rss":       "📡",
+  "pdf":       "📕",
+  "epub":      "📖",
+  "tweet":     "🐦",
+  "video":     "🎬",
+  "note":      "📝",
+  "highlight": "💡",
+}
+
+func readerCategoryIcon(category string) string {
+  if icon, ok := readerCategoryIcons[category]; ok {
+    return icon
+  }
+
+  return "•"
+}
+
+func readerCategoryName(category string) string {
+  if category == "" {
+    return "All"
+  }
+
+  name, ok := readerCategoryNames[category]
+
+  if !ok {
+    name = cases.Title(language.English).String(category)
+  }
+
+  return readerCategoryIcon(category) + " " + name
+}
+
+// The first entry is always "All", under the empty category. The selected
+// category is kept even when none of the documents belong to it, so switching
+// locations never silently drops the filter.
+func buildReaderCategories(documents []Document, selected string) []ReaderCategory {
+  counts := make(map[string]int)
+
+  for _, doc := range documents {
+    if doc.Category != "" {
+      counts[doc.Category]++
+    }
+  }
+
+  if _, exists := counts[selected]; selected != "" && !exists {
+    counts[selected] = 0
+  }
+
+  names := make([]string, 0, len(counts))
+
+  for category := range counts {
+    names = append(names, category)
+  }
+
+  rank := func(category string) int {
+    if i := slices.Index(readerCategoryOrder, category); i >= 0 {
+      return i
+    }
+
+    return len(readerCategoryOrder)
+  }
+
+  sort.Slice(names, func(i, j int) bool {
+    if a, b := rank(names[i]), rank(names[j]); a != b {
+      return a < b
+    }
+
+    return names[i] < names[j]
+  })
+
+  categories := []ReaderCategory{{Name: readerCategoryName(""), Count: len(documents)}}
+
+  for _, category := range names {
+    categories = append(categories, ReaderCategory{
+      Name:     readerCategoryName(category),
+      Count:    counts[category],
+      Category: category,
+    })
+  }
+
+  return categories
+}
+
 func buildCategories(documents []Document) []Category {
   locationCounts := make(map[string]int)
 
@@ -27,14 +152,6 @@ func buildCategories(documents []Document) []Category {
 
   var categories []Category
 
-  locationNames := map[string]string{
-    "new":       "📥 New",
-    "later":     "🕐 Later",
-    "archive":   "📦 Archive",
-    "feed":      "📰 Feed",
-    "shortlist": "⭐ Shortlist",
-  }
-
   preferredOrder := []string{"new", "later", "archive", "feed", "shortlist"}
 
   for _, location := range preferredOrder {
@@ -61,11 +178,7 @@ func buildCategories(documents []Document) []Category {
       }
 
       if !found {
-        name := locationNames[location]
-
-        if name == "" {
-          name = cases.Title(language.English).String(location)
-        }
+        name := locationName(location)
 
         categories = append(categories, Category{
           Name:     name,
@@ -79,50 +192,251 @@ func buildCategories(documents []Document) []Category {
   return categories
 }
 
-func htmlToMarkdown(html string) string {
-  if html == "" {
-    return ""
+func mergeDocuments(existing, updates []Document) []Document {
+  merged := make([]Document, len(existing))
+
+  copy(merged, existing)
+
+  index := make(map[string]int, len(merged))
+
+  for i, doc := range merged {
+    index[doc.ID] = i
+  }
+
+  for _, doc := range updates {
+    if i, exists := index[doc.ID]; exists {
+      merged[i] = doc
+    } else {
+      index[doc.ID] = len(merged)
+      merged = append(merged, doc)
+    }
   }
 
-  content := html
-  content = regexp.MustCompile(`<h1[^>]*>(.*?)</h1>`).ReplaceAllString(content, "# $1")
-  content = regexp.MustCompile(`<h2[^>]*>(.*?)</h2>`).ReplaceAllString(content, "## $1")
-  content = regexp.MustCompile(`<h3[^>]*>(.*?)</h3>`).ReplaceAllString(content, "### $1")
-  content = regexp.MustCompile(`<h4[^>]*>(.*?)</h4>`).ReplaceAllString(content, "#### $1")
-  content = regexp.MustCompile(`<h5[^>]*>(.*?)</h5>`).ReplaceAllString(content, "##### $1")
-  content = regexp.MustCompile(`<h6[^>]*>(.*?)</h6>`).ReplaceAllString(content, "###### $1")
-  content = regexp.MustCompile(`<strong[^>]*>(.*?)</strong>`).ReplaceAllString(content, "**$1**")
-  content = regexp.MustCompile(`<b[^>]*>(.*?)</b>`).ReplaceAllString(content, "**$1**")
-  content = regexp.MustCompile(`<em[^>]*>(.*?)</em>`).ReplaceAllString(content, "*$1*")
-  content = regexp.MustCompile(`<i[^>]*>(.*?)</i>`).ReplaceAllString(content, "*$1*")
-  content = regexp.MustCompile(`<a[^>]*href="([^"]*)"[^>]*>(.*?)</a>`).ReplaceAllString(content, "[$2]($1)")
-  content = regexp.MustCompile(`<p[^>]*>`).ReplaceAllString(content, "\n")
-  content = regexp.MustCompile(`</p>`).ReplaceAllString(content, "\n")
-  content = regexp.MustCompile(`<br[^>]*/?>`).ReplaceAllString(content, "\n")
-  content = regexp.MustCompile(`<blockquote[^>]*>`).ReplaceAllString(content, "\n> ")
-  content = regexp.MustCompile(`</blockquote>`).ReplaceAllString(content, "\n")
+  return merged
+}
+
+func loadCachedDocuments(cache *DocumentCache) tea.Cmd {
+  return func() tea.Msg {
+    docs, err := cache.Load()
+
+    if err != nil {
+      return cachedDocumentsLoadedMsg{err: err}
+    }
+
+    state, err := loadSyncState()
 
-  p := bluemonday.StrictPolicy()
+    if err != nil {
+      return cachedDocumentsLoadedMsg{documents: docs, err: err}
+    }
 
-  content = p.Sanitize(content)
-  content = regexp.MustCompile(`\n\s*\n\s*\n`).ReplaceAllString(content, "\n\n")
-  content = strings.TrimSpace(content)
+    // Without cached documents there is nothing to apply changes to, so the
+    // next sync has to start from scratch.
+    if len(docs) == 0 {
+      return cachedDocumentsLoadedMsg{}
+    }
 
-  return content
+    return cachedDocumentsLoadedMsg{documents: docs, lastSync: state.LastSync}
+  }
 }
 
-func loadAllDocuments(api *ReaderAPI) tea.Cmd {
+const fullSyncInterval = 24 * time.Hour
+
+// The list endpoint never reports deletions, so only a full sync (a zero
+// `since`) drops documents that were removed on the server. To keep documents
+// deleted elsewhere from lingering, an incremental sync turns into a full one
+// once the last full sync is older than fullSyncInterval. A sync limited to
+// one Reader category only covers that category, so it leaves the sync state
+// alone and a full one only drops documents of that category.
+func syncDocuments(ctx context.Context, api *ReaderAPI, cache *DocumentCache, since time.Time, category string) tea.Cmd {
   return func() tea.Msg {
-    docs, err := api.GetDocuments("", 50)
+    syncedAt := time.Now()
+
+    state, err := loadSyncState()
 
     if err != nil {
       return errorMsg(err)
     }
 
-    return allDocumentsLoadedMsg(docs)
+    if category == "" && syncedAt.Sub(state.LastFullSync) > fullSyncInterval {
+      since = time.Time{}
+    }
+
+    docs, err := api.GetDocuments(ctx, DocumentListOptions{
+      Category:     category,
+      UpdatedAfter: since,
+      WithContent:  true,
+    })
+
+    if err != nil {
+      return errorMsg(err)
+    }
+
+    // A sync superseded by a newer refresh leaves the cache to that one.
+    if err := ctx.Err(); err != nil {
+      return errorMsg(err)
+    }
+
+    switch {
+    case !since.IsZero():
+      err = cache.Put(docs...)
+    case category != "":
+      err = cache.ReplaceCategory(category, docs)
+    default:
+      err = cache.Replace(docs)
+    }
+
+    if err != nil {
+      return errorMsg(err)
+    }
+
+    msg := documentsSyncedMsg{
+      category:  category,
+      documents: docs,
+      full:      since.IsZero(),
+      syncedAt:  syncedAt,
+    }
+
+    if category != "" {
+      return msg
+    }
+
+    state.LastSync = syncedAt
+
+    if since.IsZero() {
+      state.LastFullSync = syncedAt
+    }
+
+    if err := saveSyncState(state); err != nil {
+      return errorMsg(err)
+    }
+
+    return msg
+  }
+}
+
+func withoutReaderCategory(documents []Document, category string) []Document {
+  return slices.DeleteFunc(slices.Clone(documents), func(doc Document) bool {
+    return doc.Category == category
+  })
+}
+
+func moveDocument(ctx context.Context, api *ReaderAPI, cache *DocumentCache, doc Document, previous string) tea.Cmd {
+  return func() tea.Msg {
+    if err := api.UpdateDocument(ctx, doc.ID, DocumentUpdate{Location: doc.Location}); err != nil {
+      return documentMoveFailedMsg{id: doc.ID, location: doc.Location, previous: previous, err: err}
+    }
+
+    if err := cache.Put(doc); err != nil {
+      return cacheWriteFailedMsg{err: err}
+    }
+
+    return documentMovedMsg{id: doc.ID, location: doc.Location}
   }
 }
 
+func saveReadingProgress(ctx context.Context, api *ReaderAPI, cache *DocumentCache, doc Document) tea.Cmd {
+  return func() tea.Msg {
+    if err := cache.Put(doc); err != nil {
+      return cacheWriteFailedMsg{err: err}
+    }
+
+    // Offline sessions have no API client, so the position is queued and sent
+    // at the start of the next online session, before it syncs.
+    if api == nil {
+      if err := queueReadingProgress(doc.ID, doc.ReadingProgress); err != nil {
+        return readingProgressSaveFailedMsg{err: err}
+      }
+
+      return nil
+    }
+
+    progress := doc.ReadingProgress
+
+    if err := api.UpdateDocument(ctx, doc.ID, DocumentUpdate{ReadingProgress: &progress}); err != nil {
+      return readingProgressSaveFailedMsg{err: err}
+    }
+
+    return nil
+  }
+}
+
+func queueReadingProgress(id string, progress float64) error {
+  state, err := loadSyncState()
+
+  if err != nil {
+    return err
+  }
+
+  if state.PendingProgress == nil {
+    state.PendingProgress = make(map[string]float64)
+  }
+
+  state.PendingProgress[id] = progress
+
+  return saveSyncState(state)
+}
+
+// Positions that fail to send stay queued for the next session. A document
+// deleted in the meantime can never be updated, so it is dropped instead.
+func sendQueuedProgress(ctx context.Context, api *ReaderAPI) tea.Cmd {
+  return func() tea.Msg {
+    state, err := loadSyncState()
+
+    if err != nil {
+      return readingProgressSaveFailedMsg{err: err}
+    }
+
+    if len(state.PendingProgress) == 0 {
+      return nil
+    }
+
+    var failed error
+
+    for id, progress := range state.PendingProgress {
+      err := api.UpdateDocument(ctx, id, DocumentUpdate{ReadingProgress: &progress})
+
+      if err != nil && !errors.Is(err, errDocumentNotFound) {
+        failed = err
+        continue
+      }
+
+      delete(state.PendingProgress, id)
+    }
+
+    if err := saveSyncState(state); err != nil {
+      return readingProgressSaveFailedMsg{err: err}
+    }
+
+    if failed != nil {
+      return readingProgressSaveFailedMsg{err: failed}
+    }
+
+    return nil
+  }
+}
+
+func deleteDocument(ctx context.Context, api *ReaderAPI, cache *DocumentCache, doc Document) tea.Cmd {
+  return func() tea.Msg {
+    if err := api.DeleteDocument(ctx, doc.ID); err != nil {
+      return documentDeleteFailedMsg{doc: doc, err: err}
+    }
+
+    if err := cache.Delete(doc.ID); err != nil {
+      return cacheWriteFailedMsg{err: err}
+    }
+
+    return documentDeletedMsg{doc: doc}
+  }
+}
+
+func documentURL(doc Document) string {
+  if doc.URL != "" {
+    return doc.URL
+  }
+
+  return doc.SourceURL
+}
+
 func loadDocumentContent(doc Document) tea.Cmd {
   return func() tea.Msg {
     content := doc.HTMLContent
@@ -131,8 +445,22 @@ func loadDocumentContent(doc Document) tea.Cmd {
       content = doc.Summary
     }
 
+    var images []ContentImage
+
+    var links []ContentLink
+
     if strings.Contains(content, "<") {
-      content = htmlToMarkdown(content)
+      baseURL := doc.SourceURL
+
+      if baseURL == "" {
+        baseURL = doc.URL
+      }
+
+      converted := convertHTML(content, baseURL)
+
+      content = converted.Markdown
+      images = converted.Images
+      links = converted.Links
     }
 
     if !strings.Contains(content, doc.Title) {
@@ -147,6 +475,6 @@ func loadDocumentContent(doc Document) tea.Cmd {
       content = fullContent
     }
 
-    return documentContentMsg(content)
+    return documentContentMsg{id: doc.ID, content: content, images: images, links: links}
   }
 }
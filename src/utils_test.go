@@ -0,0 +1,301 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "net/http"
+  "slices"
+  "strings"
+  "sync"
+  "testing"
+)
+
+func documentIDs(documents []Document) []string {
+  ids := make([]string, len(documents))
+
+  for i, doc := range documents {
+    ids[i] = doc.ID
+  }
+
+  return ids
+}
+
+func TestMergeDocuments(t *testing.T) {
+  tests := []struct {
+    name     string
+    existing []Document
+    updates  []Document
+    ids      []string
+    location string
+  }{
+    {
+      name:     "empty",
+      existing: nil,
+      updates:  nil,
+      ids:      []string{},
+    },
+    {
+      name:     "appends new documents",
+      existing: []Document{{ID: "a"}},
+      updates:  []Document{{ID: "b"}, {ID: "c"}},
+      ids:      []string{"a", "b", "c"},
+    },
+    {
+      name:     "replaces changed documents in place",
+      existing: []Document{{ID: "a", Location: "new"}, {ID: "b"}},
+      updates:  []Document{{ID: "a", Location: "archive"}},
+      ids:      []string{"a", "b"},
+      location: "archive",
+    },
+    {
+      name:     "deduplicates updates",
+      existing: nil,
+      updates:  []Document{{ID: "a", Location: "new"}, {ID: "a", Location: "later"}},
+      ids:      []string{"a"},
+      location: "later",
+    },
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      existing := slices.Clone(test.existing)
+
+      merged := mergeDocuments(test.existing, test.updates)
+
+      if ids := documentIDs(merged); !slices.Equal(ids, test.ids) {
+        t.Errorf("ids = %v, want %v", ids, test.ids)
+      }
+
+      if test.location != "" && merged[0].Location != test.location {
+        t.Errorf("location = %q, want %q", merged[0].Location, test.location)
+      }
+
+      if !slices.EqualFunc(existing, test.existing, func(a, b Document) bool { return a.ID == b.ID && a.Location == b.Location }) {
+        t.Errorf("existing documents were modified")
+      }
+    })
+  }
+}
+
+func TestQueuedReadingProgress(t *testing.T) {
+  t.Setenv("HOME", t.TempDir())
+
+  for id, progress := range map[string]float64{"kept": 0.5, "deleted": 0.25, "failing": 0.75} {
+    if err := queueReadingProgress(id, progress); err != nil {
+      t.Fatal(err)
+    }
+  }
+
+  var mu sync.Mutex
+
+  sent := make(map[string]float64)
+
+  api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+    id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/update/"), "/")
+
+    switch id {
+    case "deleted":
+      w.WriteHeader(http.StatusNotFound)
+    case "failing":
+      w.WriteHeader(http.StatusBadRequest)
+    default:
+      var update DocumentUpdate
+
+      if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.ReadingProgress == nil {
+        t.Errorf("invalid update for %s: %v", id, err)
+        return
+      }
+
+      mu.Lock()
+      sent[id] = *update.ReadingProgress
+      mu.Unlock()
+    }
+  })
+
+  msg := sendQueuedProgress(context.Background(), api)()
+
+  if _, ok := msg.(readingProgressSaveFailedMsg); !ok {
+    t.Errorf("expected the failed update to be reported, got %#v", msg)
+  }
+
+  if sent["kept"] != 0.5 {
+    t.Errorf("sent %v, want kept at 0.5", sent)
+  }
+
+  state, err := loadSyncState()
+
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if len(state.PendingProgress) != 1 || state.PendingProgress["failing"] != 0.75 {
+    t.Errorf("pending progress = %v, want only the failed update", state.PendingProgress)
+  }
+}
+
+func TestBuildReaderCategories(t *testing.T) {
+  docs := []Document{
+    {ID: "a", Category: "rss"},
+    {ID: "b", Category: "article"},
+    {ID: "c", Category: "podcast"},
+    {ID: "d", Category: "rss"},
+    {ID: "e"},
+    {ID: "f", Category: "audio"},
+  }
+
+  type entry struct {
+    category string
+    count    int
+  }
+
+  tests := []struct {
+    name     string
+    docs     []Document
+    selected string
+    expected []entry
+  }{
+    {
+      name:     "empty",
+      expected: []entry{{"", 0}},
+    },
+    {
+      name:     "known categories first, then the rest by name",
+      docs:     docs,
+      expected: []entry{{"", 6}, {"article", 1}, {"rss", 2}, {"audio", 1}, {"podcast", 1}},
+    },
+    {
+      name:     "keeps a selected category with no documents",
+      docs:     docs,
+      selected: "pdf",
+      expected: []entry{{"", 6}, {"article", 1}, {"rss", 2}, {"pdf", 0}, {"audio", 1}, {"podcast", 1}},
+    },
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      categories := buildReaderCategories(test.docs, test.selected)
+
+      var got []entry
+
+      for _, category := range categories {
+        got = append(got, entry{category.Category, category.Count})
+      }
+
+      if !slices.Equal(got, test.expected) {
+        t.Errorf("expected %v, got %v", test.expected, got)
+      }
+
+      if categories[0].Name != readerCategoryName("") {
+        t.Errorf("expected the first entry to be %q, got %q", readerCategoryName(""), categories[0].Name)
+      }
+    })
+  }
+}
+
+func TestMoveDocument(t *testing.T) {
+  tests := []struct {
+    name   string
+    status int
+    moved  bool
+  }{
+    {"moved", http.StatusOK, true},
+    {"rejected", http.StatusBadRequest, false},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+        var update DocumentUpdate
+
+        if err := json.NewDecoder(r.Body).Decode(&update); err != nil || r.URL.Path != "/update/a/" || update.Location != "archive" {
+          t.Errorf("unexpected update %s %+v: %v", r.URL.Path, update, err)
+        }
+
+        w.WriteHeader(test.status)
+      })
+
+      cache := &DocumentCache{dir: t.TempDir()}
+
+      msg := moveDocument(context.Background(), api, cache, Document{ID: "a", Location: "archive"}, "new")()
+
+      cached, err := cache.Load()
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if !test.moved {
+        failed, ok := msg.(documentMoveFailedMsg)
+
+        if !ok || failed.id != "a" || failed.location != "archive" || failed.previous != "new" {
+          t.Errorf("expected the move to fail back to new, got %#v", msg)
+        }
+
+        if len(cached) != 0 {
+          t.Errorf("expected nothing cached, got %v", documentIDs(cached))
+        }
+
+        return
+      }
+
+      if moved, ok := msg.(documentMovedMsg); !ok || moved.location != "archive" {
+        t.Errorf("expected the document to be moved, got %#v", msg)
+      }
+
+      if len(cached) != 1 || cached[0].Location != "archive" {
+        t.Errorf("expected the moved document to be cached, got %+v", cached)
+      }
+    })
+  }
+}
+
+func TestDeleteDocument(t *testing.T) {
+  tests := []struct {
+    name    string
+    status  int
+    deleted bool
+  }{
+    {"deleted", http.StatusNoContent, true},
+    {"not found", http.StatusNotFound, false},
+  }
+
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      api := newTestAPI(t, func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodDelete || r.URL.Path != "/delete/a/" {
+          t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+        }
+
+        w.WriteHeader(test.status)
+      })
+
+      cache := &DocumentCache{dir: t.TempDir()}
+
+      doc := Document{ID: "a", Title: "Title"}
+
+      if err := cache.Put(doc); err != nil {
+        t.Fatal(err)
+      }
+
+      msg := deleteDocument(context.Background(), api, cache, doc)()
+
+      cached, err := cache.Load()
+
+      if err != nil {
+        t.Fatal(err)
+      }
+
+      if test.deleted {
+        if _, ok := msg.(documentDeletedMsg); !ok || len(cached) != 0 {
+          t.Errorf("expected the document to be deleted, got %#v with %v cached", msg, documentIDs(cached))
+        }
+
+        return
+      }
+
+      if failed, ok := msg.(documentDeleteFailedMsg); !ok || failed.doc.ID != "a" || len(cached) != 1 {
+        t.Errorf("expected the delete to fail and keep the cache, got %#v with %v cached", msg, documentIDs(cached))
+      }
+    })
+  }
+}